@@ -0,0 +1,243 @@
+package cachehandler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultScanCount = 500
+
+// KeyResult is the outcome of a batch operation for one key. Err is ErrMiss
+// for a key that does not exist.
+type KeyResult struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+// Pipe queues commands for Pipeline and TxPipeline. The returned results are
+// filled in once the pipeline has been executed.
+type Pipe interface {
+	Set(string, string, time.Duration) CacheResult
+	Get(string) CacheResult
+	Delete(string) CacheResult
+}
+
+type pipe struct {
+	ctx context.Context
+	p   redis.Pipeliner
+}
+
+func (p *pipe) Set(key, value string, ttl time.Duration) CacheResult {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+	return p.p.Set(p.ctx, key, value, exp)
+}
+
+func (p *pipe) Get(key string) CacheResult {
+	return p.p.Get(p.ctx, key)
+}
+
+func (p *pipe) Delete(key string) CacheResult {
+	return &DelCacheResult{cmd: p.p.Del(p.ctx, key)}
+}
+
+// MGet reads keys in one round-trip. On a cluster the keys may live on
+// different nodes, so they are read with a pipeline of GETs instead of MGET.
+func (c *cacheHandler) MGet(ctx context.Context, keys ...string) ([]KeyResult, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	if _, ok := c.client.(*redis.ClusterClient); ok {
+		cmds := make([]*redis.StringCmd, len(keys))
+		err := c.pipelined(ctx, false, func(p redis.Pipeliner) {
+			for i, key := range keys {
+				cmds[i] = p.Get(ctx, key)
+			}
+		})
+
+		results := make([]KeyResult, len(keys))
+		for i, cmd := range cmds {
+			results[i] = KeyResult{Key: keys[i], Value: cmd.Val(), Err: cmd.Err()}
+			if errors.Is(cmd.Err(), redis.Nil) {
+				results[i].Err = ErrMiss
+			}
+		}
+		return results, err
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]KeyResult, len(keys))
+	for i, key := range keys {
+		results[i] = KeyResult{Key: key}
+		switch v := values[i].(type) {
+		case nil:
+			results[i].Err = ErrMiss
+		case string:
+			results[i].Value = v
+		}
+	}
+	return results, nil
+}
+
+// MSet writes all values with the same ttl in one round-trip
+func (c *cacheHandler) MSet(ctx context.Context, values map[string]string, ttl time.Duration) ([]KeyResult, error) {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(values))
+	cmds := make([]*redis.StatusCmd, 0, len(values))
+	err = c.pipelined(ctx, false, func(p redis.Pipeliner) {
+		for key, value := range values {
+			keys = append(keys, key)
+			cmds = append(cmds, p.Set(ctx, key, value, exp))
+		}
+	})
+
+	results := make([]KeyResult, len(keys))
+	for i, cmd := range cmds {
+		results[i] = KeyResult{Key: keys[i], Value: cmd.Val(), Err: cmd.Err()}
+	}
+	return results, err
+}
+
+// DeleteMany deletes keys in one round-trip. The Value of a result is the
+// number of deleted keys, with Err set to ErrMiss when it did not exist.
+func (c *cacheHandler) DeleteMany(ctx context.Context, keys ...string) ([]KeyResult, error) {
+	cmds := make([]*redis.IntCmd, len(keys))
+	err := c.pipelined(ctx, false, func(p redis.Pipeliner) {
+		for i, key := range keys {
+			cmds[i] = p.Del(ctx, key)
+		}
+	})
+
+	results := make([]KeyResult, len(keys))
+	for i, cmd := range cmds {
+		res := &DelCacheResult{cmd: cmd}
+		results[i] = KeyResult{Key: keys[i], Value: res.Val(), Err: cmd.Err()}
+		if cmd.Err() == nil && cmd.Val() == 0 {
+			results[i].Err = ErrMiss
+		}
+	}
+	return results, err
+}
+
+// DeleteByPattern walks the keyspace with SCAN, so Redis is never blocked as
+// with KEYS, and unlinks the matching keys. It returns how many were deleted.
+// The walk is bounded by ctx only, the call timeout is not applied to it.
+func (c *cacheHandler) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return scanDelete(ctx, c.client, pattern, false)
+	}
+
+	var deleted int64
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		n, err := scanDelete(ctx, node, pattern, true)
+		atomic.AddInt64(&deleted, n)
+		return err
+	})
+	return deleted, err
+}
+
+// scanDelete deletes the keys matching pattern on one server. Keys of a
+// cluster node can belong to different slots and are unlinked one by one.
+func scanDelete(ctx context.Context, client redis.UniversalClient, pattern string, perKey bool) (int64, error) {
+	var (
+		cursor  uint64
+		deleted int64
+	)
+	for {
+		keys, next, err := client.Scan(ctx, cursor, pattern, defaultScanCount).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(keys) > 0 && !perKey {
+			n, err := client.Unlink(ctx, keys...).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		} else if len(keys) > 0 {
+			cmds, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+				for _, key := range keys {
+					p.Unlink(ctx, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return deleted, err
+			}
+			for _, cmd := range cmds {
+				deleted += cmd.(*redis.IntCmd).Val()
+			}
+		}
+
+		if cursor = next; cursor == 0 {
+			return deleted, nil
+		}
+	}
+}
+
+// Pipeline sends the commands queued by fn in one round-trip
+func (c *cacheHandler) Pipeline(ctx context.Context, fn func(Pipe) error) error {
+	return c.pipe(ctx, false, fn)
+}
+
+// TxPipeline is Pipeline wrapped in MULTI/EXEC, so the commands run atomically.
+// On a cluster all the keys must hash to the same slot.
+func (c *cacheHandler) TxPipeline(ctx context.Context, fn func(Pipe) error) error {
+	return c.pipe(ctx, true, fn)
+}
+
+func (c *cacheHandler) pipe(ctx context.Context, tx bool, fn func(Pipe) error) error {
+	var fnErr error
+	err := c.pipelined(ctx, tx, func(p redis.Pipeliner) {
+		fnErr = fn(&pipe{ctx: ctx, p: p})
+		if fnErr != nil {
+			p.Discard()
+		}
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// pipelined executes the queued commands. A redis.Nil from a missing key is a
+// per command result, not an error of the pipeline.
+func (c *cacheHandler) pipelined(ctx context.Context, tx bool, fn func(redis.Pipeliner)) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var p redis.Pipeliner
+	if tx {
+		p = c.client.TxPipeline()
+	} else {
+		p = c.client.Pipeline()
+	}
+	fn(p)
+
+	_, err := p.Exec(ctx)
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
@@ -2,25 +2,56 @@ package cachehandler
 
 import (
 	"context"
-	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/Abhi-singh-karuna/my_Liberary/baselogger"
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
 	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+	"github.com/Abhi-singh-karuna/my_Liberary/validator"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// NoExpiry stores a key without expiration
+	NoExpiry time.Duration = -1
+	// KeepTTL keeps the expiration a key already has when overwriting it
+	KeepTTL time.Duration = -2
+
+	defaultPingTimeout = 5 * time.Second
+)
+
 type cacheHandler struct {
-	log    logger.Logger
-	client *redis.Client
+	log     logger.Logger
+	client  redis.UniversalClient
+	timeout time.Duration
 }
 
 type CacheHandler interface {
 	Set(string, string, int) CacheResult
 	Get(string) CacheResult
 	Delete(string) CacheResult
+	SetContext(context.Context, string, string, int) CacheResult
+	GetContext(context.Context, string) CacheResult
+	DeleteContext(context.Context, string) CacheResult
+	SetWithTTL(context.Context, string, string, time.Duration) CacheResult
+	SetNX(context.Context, string, string, time.Duration) (bool, error)
+	SetXX(context.Context, string, string, time.Duration) (bool, error)
+	Expire(context.Context, string, time.Duration) (bool, error)
+	TTL(context.Context, string) (time.Duration, error)
+	Persist(context.Context, string) (bool, error)
+	TryLock(context.Context, string, time.Duration) (Lock, error)
+	Lock(context.Context, string, time.Duration) (Lock, error)
+	Allow(context.Context, string, Limit) (*RateLimitResult, error)
+	MGet(context.Context, ...string) ([]KeyResult, error)
+	MSet(context.Context, map[string]string, time.Duration) ([]KeyResult, error)
+	DeleteMany(context.Context, ...string) ([]KeyResult, error)
+	DeleteByPattern(context.Context, string) (int64, error)
+	Pipeline(context.Context, func(Pipe) error) error
+	TxPipeline(context.Context, func(Pipe) error) error
+	SetWithTags(context.Context, string, string, time.Duration, ...string) CacheResult
+	InvalidateTags(context.Context, ...string) (int64, error)
 }
 
 type CacheResult interface {
@@ -37,30 +68,190 @@ type Cache struct {
 	DB       int
 }
 
+// NewCacheHandler builds the handler without checking that Redis is
+// reachable. Prefer ConnectCacheHandler, which fails fast on a bad config.
 func NewCacheHandler(cfg Redis, log *baselogger.BaseLogger) CacheHandler {
-	log.Infof("Host :-  %v   -- port  %v  ---  Pass %v  --- DB  %v", cfg.GetHost(), cfg.GetPort(), cfg.GetPassword(), cfg.GetDatabase())
-	log.Info("CacheHandler created variables from Config")
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.GetHost(), cfg.GetPort()),
-		Password: cfg.GetPassword(),
-		DB:       cfg.GetDatabase(),
-	})
+	log.Infof("CacheHandler created variables from Config: %s", cfg)
+	redisClient, err := newRedisClient(&cfg)
+	if err != nil {
+		log.Panic(err)
+	}
+	log.Debugf("CacheHandler prepared %s redis client", cfg.GetMode())
+	return &cacheHandler{
+		log:     log,
+		client:  redisClient,
+		timeout: cfg.GetTimeout(),
+	}
+}
+
+// ConnectCacheHandler validates the config, builds the client and pings Redis,
+// bounded by ctx or by the dial timeout when ctx has no deadline
+func ConnectCacheHandler(ctx context.Context, cfg Redis, log logger.Logger) (CacheHandler, error) {
+	if err := validator.ValidateStruct(ctx, &cfg); err != nil {
+		return nil, errs.Invalidated.Wrap(err, "invalid redis config")
+	}
+	log.Infof("CacheHandler created variables from Config: %s", cfg)
+
+	redisClient, err := newRedisClient(&cfg)
+	if err != nil {
+		return nil, errs.Invalidated.Wrap(err, "invalid redis config")
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := cfg.GetDialTimeout()
+		if timeout <= 0 {
+			timeout = defaultPingTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		redisClient.Close()
+		return nil, errs.Failed.Wrapf(err, "ping redis at %v", cfg.GetAddrs())
+	}
+	log.Debugf("CacheHandler connected %s redis client", cfg.GetMode())
+
 	return &cacheHandler{
-		log:    log,
-		client: redisClient,
+		log:     log,
+		client:  redisClient,
+		timeout: cfg.GetTimeout(),
+	}, nil
+}
+
+// withTimeout bounds a call by the configured timeout, unless ctx already
+// has an earlier deadline
+func (c *cacheHandler) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= c.timeout {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, c.timeout)
 }
 
+// expiration converts a ttl to the value go-redis expects. A zero or negative
+// ttl is rejected, no expiry has to be asked for with NoExpiry.
+func expiration(ttl time.Duration) (time.Duration, error) {
+	switch {
+	case ttl == NoExpiry:
+		return 0, nil
+	case ttl == KeepTTL:
+		return redis.KeepTTL, nil
+	case ttl <= 0:
+		return 0, errs.Invalidated.Errorf("invalid cache ttl %v, use NoExpiry for keys without expiration", ttl)
+	default:
+		return ttl, nil
+	}
+}
+
+// Set stores value for ttl hours, 0 meaning no expiry. Prefer SetWithTTL.
 func (c *cacheHandler) Set(key, value string, ttl int) CacheResult {
-	return c.client.Set(context.Background(), key, value, time.Hour*time.Duration(ttl))
+	return c.SetContext(context.Background(), key, value, ttl)
 }
 
 func (c *cacheHandler) Get(key string) CacheResult {
-	return c.client.Get(context.Background(), key)
+	return c.GetContext(context.Background(), key)
 }
 
 func (c *cacheHandler) Delete(key string) CacheResult {
-	return &DelCacheResult{cmd: c.client.Del(context.Background(), key)}
+	return c.DeleteContext(context.Background(), key)
+}
+
+func (c *cacheHandler) SetContext(ctx context.Context, key, value string, ttl int) CacheResult {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.Set(ctx, key, value, time.Hour*time.Duration(ttl))
+}
+
+func (c *cacheHandler) GetContext(ctx context.Context, key string) CacheResult {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.Get(ctx, key)
+}
+
+func (c *cacheHandler) DeleteContext(ctx context.Context, key string) CacheResult {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return &DelCacheResult{cmd: c.client.Del(ctx, key)}
+}
+
+// SetWithTTL stores value for the given duration, NoExpiry or KeepTTL
+func (c *cacheHandler) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) CacheResult {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.Set(ctx, key, value, exp)
+}
+
+// SetNX stores value only if the key does not exist and reports whether it did
+func (c *cacheHandler) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.SetNX(ctx, key, value, exp).Result()
+}
+
+// SetXX stores value only if the key already exists and reports whether it did
+func (c *cacheHandler) SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.SetXX(ctx, key, value, exp).Result()
+}
+
+// Expire sets a new ttl on an existing key, NoExpiry removes it
+func (c *cacheHandler) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl == NoExpiry {
+		return c.Persist(ctx, key)
+	}
+	if ttl <= 0 {
+		return false, errs.Invalidated.Errorf("invalid cache ttl %v", ttl)
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.PExpire(ctx, key, ttl).Result()
+}
+
+// TTL returns the remaining time to live of a key, NoExpiry for a key without
+// expiration and ErrMiss when the key does not exist
+func (c *cacheHandler) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	ttl, err := c.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	switch ttl {
+	case -2:
+		return 0, ErrMiss
+	case -1:
+		return NoExpiry, nil
+	}
+	return ttl, nil
+}
+
+// Persist removes the expiration of a key
+func (c *cacheHandler) Persist(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.Persist(ctx, key).Result()
 }
 
 // DelCacheResult is a wrapper around *redis.IntCmd to implement CacheResult
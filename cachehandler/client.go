@@ -0,0 +1,97 @@
+package cachehandler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newRedisClient builds the client for the configured mode; all of them are
+// used through redis.UniversalClient
+func newRedisClient(cfg *Redis) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.GetMode() {
+	case ModeStandalone:
+		return redis.NewClient(&redis.Options{
+			Addr:         cfg.GetAddrs()[0],
+			Username:     cfg.GetUser(),
+			Password:     cfg.GetPassword(),
+			DB:           cfg.GetDatabase(),
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.GetPoolSize(),
+			MinIdleConns: cfg.GetMinIdleConns(),
+			DialTimeout:  cfg.GetDialTimeout(),
+			ReadTimeout:  cfg.GetReadTimeout(),
+			WriteTimeout: cfg.GetWriteTimeout(),
+		}), nil
+	case ModeSentinel:
+		if cfg.GetMasterName() == "" {
+			return nil, errors.New("redis sentinel mode needs a master name")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.GetMasterName(),
+			SentinelAddrs:    cfg.GetAddrs(),
+			SentinelUsername: cfg.GetSentinelUser(),
+			SentinelPassword: cfg.GetSentinelPassword(),
+			Username:         cfg.GetUser(),
+			Password:         cfg.GetPassword(),
+			DB:               cfg.GetDatabase(),
+			TLSConfig:        tlsConfig,
+			PoolSize:         cfg.GetPoolSize(),
+			MinIdleConns:     cfg.GetMinIdleConns(),
+			DialTimeout:      cfg.GetDialTimeout(),
+			ReadTimeout:      cfg.GetReadTimeout(),
+			WriteTimeout:     cfg.GetWriteTimeout(),
+		}), nil
+	case ModeCluster:
+		if cfg.GetDatabase() != 0 {
+			return nil, errors.New("redis cluster only supports database 0")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.GetAddrs(),
+			Username:     cfg.GetUser(),
+			Password:     cfg.GetPassword(),
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.GetPoolSize(),
+			MinIdleConns: cfg.GetMinIdleConns(),
+			DialTimeout:  cfg.GetDialTimeout(),
+			ReadTimeout:  cfg.GetReadTimeout(),
+			WriteTimeout: cfg.GetWriteTimeout(),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.GetMode())
+	}
+}
+
+func newTLSConfig(cfg *Redis) (*tls.Config, error) {
+	if !cfg.GetTLS() {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.GetTLSServerName(),
+		InsecureSkipVerify: cfg.GetTLSInsecureSkipVerify(),
+	}
+
+	if caFile := cfg.GetTLSCAFile(); caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
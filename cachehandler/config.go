@@ -1,11 +1,45 @@
 package cachehandler
 
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+// Redis configures the cache connection. Mode selects a single node from
+// Host and Port (the default), Sentinel from MasterName and the sentinel
+// Addrs, or Cluster from the node Addrs. User is the ACL username and can be
+// left empty for the default user, Password is empty for a Redis without auth.
 type Redis struct {
-	Host     string `validate:"required"`
-	Database int `validate:"required"`
-	User     string `validate:"required"`
-	Password string `validate:"required"`
-	Port     string `validate:"required"`
+	Host     string `validate:"required_without=Addrs"`
+	Database int    `validate:"min=0"`
+	User     string
+	Password string
+	Port     string `validate:"required_without=Addrs"`
+	Timeout  time.Duration
+
+	Mode             string `validate:"omitempty,oneof=standalone sentinel cluster"`
+	MasterName       string `validate:"required_if=Mode sentinel"`
+	Addrs            []string
+	SentinelUser     string
+	SentinelPassword string
+
+	TLS                   bool
+	TLSCAFile             string
+	TLSServerName         string
+	TLSInsecureSkipVerify bool
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
 }
 
 func (sql *Redis) GetHost() string {
@@ -28,5 +62,108 @@ func (sql *Redis) GetPort() string {
 	return sql.Port
 }
 
+func (sql *Redis) GetTimeout() time.Duration {
+	return sql.Timeout
+}
+
+func (sql *Redis) GetMode() string {
+	if sql.Mode == "" {
+		return ModeStandalone
+	}
+	return sql.Mode
+}
+
+func (sql *Redis) GetMasterName() string {
+	return sql.MasterName
+}
+
+// GetAddrs returns the sentinel or cluster addresses, or Host:Port when none are set
+func (sql *Redis) GetAddrs() []string {
+	if len(sql.Addrs) == 0 {
+		return []string{net.JoinHostPort(sql.Host, sql.Port)}
+	}
+	return sql.Addrs
+}
+
+func (sql *Redis) GetSentinelUser() string {
+	return sql.SentinelUser
+}
+
+func (sql *Redis) GetSentinelPassword() string {
+	return sql.SentinelPassword
+}
+
+func (sql *Redis) GetTLS() bool {
+	return sql.TLS
+}
+
+func (sql *Redis) GetTLSCAFile() string {
+	return sql.TLSCAFile
+}
+
+func (sql *Redis) GetTLSServerName() string {
+	return sql.TLSServerName
+}
+
+func (sql *Redis) GetTLSInsecureSkipVerify() bool {
+	return sql.TLSInsecureSkipVerify
+}
 
-// 	log.Infof("Host :-  %v   -- port  %v  ---  Pass %v  --- DB  %v", cfg.Redis.Write.Host, cfg.Redis.Write.Port, cfg.Redis.Write.Password, cfg.Redis.Write.Db)
+func (sql *Redis) GetPoolSize() int {
+	return sql.PoolSize
+}
+
+func (sql *Redis) GetMinIdleConns() int {
+	return sql.MinIdleConns
+}
+
+func (sql *Redis) GetDialTimeout() time.Duration {
+	return sql.DialTimeout
+}
+
+func (sql *Redis) GetReadTimeout() time.Duration {
+	return sql.ReadTimeout
+}
+
+func (sql *Redis) GetWriteTimeout() time.Duration {
+	return sql.WriteTimeout
+}
+
+// String describes the config for logs with the secrets redacted
+func (sql Redis) String() string {
+	return fmt.Sprintf("mode=%s addrs=%v db=%d user=%q password=%s tls=%t",
+		sql.GetMode(), sql.GetAddrs(), sql.Database, sql.User, redact(sql.Password), sql.TLS)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return `""`
+	}
+	return "[REDACTED]"
+}
+
+// Memory bounds the in-process cache by number of entries and/or bytes, 0
+// meaning no bound. In the tiered handler LocalTTL caps how long a value is
+// kept locally and Channel is the Redis pub/sub channel of invalidations.
+type Memory struct {
+	MaxEntries int   `validate:"min=0"`
+	MaxBytes   int64 `validate:"min=0"`
+	LocalTTL   time.Duration
+	Channel    string
+}
+
+func (m *Memory) GetMaxEntries() int {
+	return m.MaxEntries
+}
+
+func (m *Memory) GetMaxBytes() int64 {
+	return m.MaxBytes
+}
+
+func (m *Memory) GetLocalTTL() time.Duration {
+	return m.LocalTTL
+}
+
+func (m *Memory) GetChannel() string {
+	return m.Channel
+}
@@ -0,0 +1,281 @@
+package cachehandler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	lockRetryMin = 10 * time.Millisecond
+	lockRetryMax = 500 * time.Millisecond
+)
+
+var (
+	ErrLockNotObtained = errors.New("lock not obtained")
+	ErrLockNotHeld     = errors.New("lock not held")
+
+	errLockTTL = errors.New("lock ttl must be at least 1ms")
+)
+
+var (
+	releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+	extendScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// Lock is a held distributed lock. Its lease is extended automatically until
+// Release; Lost is closed once another owner holds the key or the lease ran
+// out while extensions kept failing.
+type Lock interface {
+	Key() string
+	Token() string
+	Refresh(context.Context, time.Duration) error
+	Release(context.Context) error
+	Lost() <-chan struct{}
+}
+
+// lockStore is the storage a lock is kept in
+type lockStore interface {
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	release(ctx context.Context, key, token string) (bool, error)
+	extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+}
+
+// lease is the ttl of a lock and when it runs out if not extended
+type lease struct {
+	ttl      time.Duration
+	deadline time.Time
+}
+
+type lock struct {
+	log   logger.Logger
+	store lockStore
+	key   string
+	token string
+	lease lease
+
+	mu   sync.Mutex
+	ttlC chan lease
+	stop chan struct{}
+	lost chan struct{}
+	done bool
+}
+
+// TryLock acquires key for ttl once and returns ErrLockNotObtained if it is held
+func (c *cacheHandler) TryLock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	return tryLock(ctx, c.log, c, key, ttl)
+}
+
+// Lock waits until key can be acquired or ctx is done
+func (c *cacheHandler) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	return waitLock(ctx, c.log, c, key, ttl)
+}
+
+func (c *cacheHandler) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.SetNX(ctx, key, token, ttl).Result()
+}
+
+func (c *cacheHandler) release(ctx context.Context, key, token string) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	n, err := releaseScript.Run(ctx, c.client, []string{key}, token).Int64()
+	return n == 1, err
+}
+
+func (c *cacheHandler) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	n, err := extendScript.Run(ctx, c.client, []string{key}, token, ttl.Milliseconds()).Int64()
+	return n == 1, err
+}
+
+func tryLock(ctx context.Context, log logger.Logger, store lockStore, key string, ttl time.Duration) (Lock, error) {
+	if ttl < time.Millisecond {
+		return nil, errLockTTL
+	}
+
+	token, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	ok, err := store.acquire(ctx, key, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockNotObtained
+	}
+
+	l := &lock{
+		log:   log,
+		store: store,
+		key:   key,
+		token: token,
+		lease: lease{ttl: ttl, deadline: start.Add(ttl)},
+		ttlC:  make(chan lease, 1),
+		stop:  make(chan struct{}),
+		lost:  make(chan struct{}),
+	}
+	go l.keepAlive()
+	return l, nil
+}
+
+// waitLock retries tryLock with backoff. When ctx is done first the error is
+// ErrLockNotObtained wrapping ctx.Err().
+func waitLock(ctx context.Context, log logger.Logger, store lockStore, key string, ttl time.Duration) (Lock, error) {
+	wait := lockRetryMin
+	for {
+		l, err := tryLock(ctx, log, store, key, ttl)
+		if !errors.Is(err, ErrLockNotObtained) {
+			return l, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %w", ErrLockNotObtained, ctx.Err())
+		case <-timer.C:
+		}
+		if wait *= 2; wait > lockRetryMax {
+			wait = lockRetryMax
+		}
+	}
+}
+
+func lockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (l *lock) Key() string {
+	return l.key
+}
+
+func (l *lock) Token() string {
+	return l.token
+}
+
+func (l *lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Refresh extends the lease to ttl from now and uses ttl for later extensions
+func (l *lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return errLockTTL
+	}
+
+	start := time.Now()
+	if err := l.extend(ctx, ttl); err != nil {
+		return err
+	}
+
+	select {
+	case <-l.ttlC:
+	default:
+	}
+	// keepAlive is gone once the lock is released or lost
+	select {
+	case l.ttlC <- lease{ttl: ttl, deadline: start.Add(ttl)}:
+		return nil
+	case <-l.stop:
+		return ErrLockNotHeld
+	case <-l.lost:
+		return ErrLockNotHeld
+	}
+}
+
+// Release deletes the key if it still holds this lock's token
+func (l *lock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.done {
+		l.done = true
+		close(l.stop)
+	}
+	l.mu.Unlock()
+
+	ok, err := l.store.release(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (l *lock) extend(ctx context.Context, ttl time.Duration) error {
+	ok, err := l.store.extend(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// keepAlive extends the lease every third of its ttl until Release. A failed
+// extension is retried until the lease has run out, the lock is only lost
+// then or when Redis tells that another owner holds the key.
+func (l *lock) keepAlive() {
+	current := l.lease
+	timer := time.NewTimer(current.ttl / 3)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case current = <-l.ttlC:
+			timer.Reset(current.ttl / 3)
+		case <-timer.C:
+			start := time.Now()
+			ctx, cancel := context.WithDeadline(context.Background(), current.deadline)
+			err := l.extend(ctx, current.ttl)
+			cancel()
+
+			switch {
+			case err == nil:
+				current.deadline = start.Add(current.ttl)
+				timer.Reset(current.ttl / 3)
+			case errors.Is(err, ErrLockNotHeld) || !time.Now().Before(current.deadline):
+				l.log.Warnf("Lost lock %s: %v", l.key, err)
+				close(l.lost)
+				return
+			default:
+				retry := current.ttl / 10
+				if remaining := time.Until(current.deadline); remaining < retry {
+					retry = remaining
+				}
+				l.log.Warnf("Extending lock %s failed, retrying in %v: %v", l.key, retry, err)
+				timer.Reset(retry)
+			}
+		}
+	}
+}
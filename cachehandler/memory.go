@@ -0,0 +1,576 @@
+package cachehandler
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// entryOverhead approximates the bookkeeping bytes of an entry for MaxBytes
+const entryOverhead = 64
+
+type memoryEntry struct {
+	key     string
+	value   string
+	expires time.Time
+	limit   *limitState
+	tags    []string
+}
+
+// limitState is the rate limiter state kept under a key by Allow
+type limitState struct {
+	hits   []time.Time
+	tokens float64
+	ts     time.Time
+}
+
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key) + len(e.value) + entryOverhead)
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// memoryCache is a process local CacheHandler: an LRU bounded by MaxEntries
+// and/or MaxBytes whose entries expire like Redis keys. Locks and rate limits
+// only hold within the process.
+type memoryCache struct {
+	log        logger.Logger
+	maxEntries int
+	maxBytes   int64
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+	tags  map[string]map[string]struct{}
+}
+
+func NewMemoryCacheHandler(cfg Memory, log logger.Logger) CacheHandler {
+	return newMemoryCache(cfg, log)
+}
+
+func newMemoryCache(cfg Memory, log logger.Logger) *memoryCache {
+	return &memoryCache{
+		log:        log,
+		maxEntries: cfg.GetMaxEntries(),
+		maxBytes:   cfg.GetMaxBytes(),
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+// lookup returns the live entry of key, dropping it if it expired. mu must be held.
+func (m *memoryCache) lookup(key string, now time.Time) *memoryEntry {
+	el, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*memoryEntry)
+	if e.expired(now) {
+		m.remove(el)
+		return nil
+	}
+	m.ll.MoveToFront(el)
+	return e
+}
+
+// store writes the entry and evicts the least recently used ones over the
+// bounds. mu must be held.
+func (m *memoryCache) store(e *memoryEntry) {
+	if el, ok := m.items[e.key]; ok {
+		m.remove(el)
+	}
+	m.items[e.key] = m.ll.PushFront(e)
+	m.bytes += e.size()
+
+	for m.ll.Len() > 1 && (m.maxEntries > 0 && m.ll.Len() > m.maxEntries || m.maxBytes > 0 && m.bytes > m.maxBytes) {
+		m.remove(m.ll.Back())
+	}
+}
+
+func (m *memoryCache) remove(el *list.Element) {
+	e := m.ll.Remove(el).(*memoryEntry)
+	delete(m.items, e.key)
+	m.bytes -= e.size()
+
+	for _, tag := range e.tags {
+		delete(m.tags[tag], e.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+func (m *memoryCache) set(key, value string, exp time.Duration, now time.Time) {
+	e := &memoryEntry{key: key, value: value}
+	switch {
+	case exp == redis.KeepTTL:
+		if old := m.lookup(key, now); old != nil {
+			e.expires = old.expires
+		}
+	case exp > 0:
+		e.expires = now.Add(exp)
+	}
+	m.store(e)
+}
+
+func (m *memoryCache) get(key string, now time.Time) (string, bool) {
+	e := m.lookup(key, now)
+	if e == nil || e.limit != nil {
+		return "", false
+	}
+	return e.value, true
+}
+
+func (m *memoryCache) del(key string, now time.Time) int64 {
+	if m.lookup(key, now) == nil {
+		return 0
+	}
+	m.remove(m.items[key])
+	return 1
+}
+
+func (m *memoryCache) Set(key, value string, ttl int) CacheResult {
+	return m.SetContext(context.Background(), key, value, ttl)
+}
+
+func (m *memoryCache) Get(key string) CacheResult {
+	return m.GetContext(context.Background(), key)
+}
+
+func (m *memoryCache) Delete(key string) CacheResult {
+	return m.DeleteContext(context.Background(), key)
+}
+
+func (m *memoryCache) SetContext(ctx context.Context, key, value string, ttl int) CacheResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, value, time.Hour*time.Duration(ttl), time.Now())
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *memoryCache) GetContext(ctx context.Context, key string) CacheResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value, ok := m.get(key, time.Now()); ok {
+		return redis.NewStringResult(value, nil)
+	}
+	return redis.NewStringResult("", redis.Nil)
+}
+
+func (m *memoryCache) DeleteContext(ctx context.Context, key string) CacheResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &DelCacheResult{cmd: redis.NewIntResult(m.del(key, time.Now()), nil)}
+}
+
+func (m *memoryCache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) CacheResult {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, value, exp, time.Now())
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *memoryCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return m.setIf(key, value, ttl, false)
+}
+
+func (m *memoryCache) SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return m.setIf(key, value, ttl, true)
+}
+
+func (m *memoryCache) setIf(key, value string, ttl time.Duration, exists bool) (bool, error) {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	old := m.lookup(key, now)
+	if (old != nil) != exists {
+		return false, nil
+	}
+	m.set(key, value, exp, now)
+	// SetXX updates the key in place, it keeps its tags as a Redis key stays
+	// in its tag sets
+	if old != nil && len(old.tags) > 0 {
+		m.tag(m.items[key].Value.(*memoryEntry), old.tags)
+	}
+	return true, nil
+}
+
+func (m *memoryCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl == NoExpiry {
+		return m.Persist(ctx, key)
+	}
+	if ttl <= 0 {
+		return false, errs.Invalidated.Errorf("invalid cache ttl %v", ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e := m.lookup(key, now)
+	if e == nil {
+		return false, nil
+	}
+	e.expires = now.Add(ttl)
+	return true, nil
+}
+
+func (m *memoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e := m.lookup(key, now)
+	switch {
+	case e == nil:
+		return 0, ErrMiss
+	case e.expires.IsZero():
+		return NoExpiry, nil
+	}
+	return e.expires.Sub(now), nil
+}
+
+func (m *memoryCache) Persist(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.lookup(key, time.Now())
+	if e == nil || e.expires.IsZero() {
+		return false, nil
+	}
+	e.expires = time.Time{}
+	return true, nil
+}
+
+func (m *memoryCache) TryLock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	return tryLock(ctx, m.log, m, key, ttl)
+}
+
+func (m *memoryCache) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	return waitLock(ctx, m.log, m, key, ttl)
+}
+
+func (m *memoryCache) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return m.SetNX(ctx, key, token, ttl)
+}
+
+func (m *memoryCache) release(ctx context.Context, key, token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if value, ok := m.get(key, now); !ok || value != token {
+		return false, nil
+	}
+	return m.del(key, now) == 1, nil
+}
+
+func (m *memoryCache) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e := m.lookup(key, now)
+	if e == nil || e.limit != nil || e.value != token {
+		return false, nil
+	}
+	e.expires = now.Add(ttl)
+	return true, nil
+}
+
+func (m *memoryCache) Allow(ctx context.Context, key string, limit Limit) (*RateLimitResult, error) {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return nil, errors.New("rate limit needs a positive rate and period")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e := m.lookup(key, now)
+	if e == nil || e.limit == nil {
+		e = &memoryEntry{key: key, limit: &limitState{}}
+	}
+	state := e.limit
+	res := &RateLimitResult{Limit: limit.Rate}
+
+	switch limit.Algorithm {
+	case TokenBucket:
+		burst := float64(limit.Rate)
+		if limit.Burst > 0 {
+			burst = float64(limit.Burst)
+			res.Limit = limit.Burst
+		}
+		perNs := float64(limit.Rate) / float64(limit.Period)
+		if state.ts.IsZero() {
+			state.tokens = burst
+		} else {
+			state.tokens = math.Min(burst, state.tokens+float64(now.Sub(state.ts))*perNs)
+		}
+		state.ts = now
+
+		if state.tokens >= 1 {
+			state.tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = time.Duration(math.Ceil((1 - state.tokens) / perNs))
+		}
+		res.Remaining = int(state.tokens)
+		res.ResetAfter = time.Duration(math.Ceil((burst - state.tokens) / perNs))
+		e.expires = now.Add(res.ResetAfter)
+	default:
+		hits := state.hits[:0]
+		for _, hit := range state.hits {
+			if now.Sub(hit) < limit.Period {
+				hits = append(hits, hit)
+			}
+		}
+		if len(hits) < limit.Rate {
+			hits = append(hits, now)
+			res.Allowed = true
+			res.Remaining = limit.Rate - len(hits)
+		} else {
+			res.RetryAfter = hits[0].Add(limit.Period).Sub(now)
+		}
+		state.hits = hits
+		res.ResetAfter = hits[len(hits)-1].Add(limit.Period).Sub(now)
+		e.expires = now.Add(res.ResetAfter)
+	}
+
+	m.store(e)
+	return res, nil
+}
+
+func (m *memoryCache) MGet(ctx context.Context, keys ...string) ([]KeyResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	results := make([]KeyResult, len(keys))
+	for i, key := range keys {
+		results[i] = KeyResult{Key: key}
+		value, ok := m.get(key, now)
+		if !ok {
+			results[i].Err = ErrMiss
+			continue
+		}
+		results[i].Value = value
+	}
+	return results, nil
+}
+
+func (m *memoryCache) MSet(ctx context.Context, values map[string]string, ttl time.Duration) ([]KeyResult, error) {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	results := make([]KeyResult, 0, len(values))
+	for key, value := range values {
+		m.set(key, value, exp, now)
+		results = append(results, KeyResult{Key: key, Value: "OK"})
+	}
+	return results, nil
+}
+
+func (m *memoryCache) DeleteMany(ctx context.Context, keys ...string) ([]KeyResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	results := make([]KeyResult, len(keys))
+	for i, key := range keys {
+		n := m.del(key, now)
+		results[i] = KeyResult{Key: key, Value: strconv.FormatInt(n, 10)}
+		if n == 0 {
+			results[i].Err = ErrMiss
+		}
+	}
+	return results, nil
+}
+
+func (m *memoryCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for key, el := range m.items {
+		if !globMatch(pattern, key) {
+			continue
+		}
+		if !el.Value.(*memoryEntry).expired(now) {
+			deleted++
+		}
+		m.remove(el)
+	}
+	return deleted, nil
+}
+
+// Pipeline queues the commands and applies them together once fn returns
+func (m *memoryCache) Pipeline(ctx context.Context, fn func(Pipe) error) error {
+	return m.TxPipeline(ctx, fn)
+}
+
+// TxPipeline applies the queued commands under one lock, none if fn fails
+func (m *memoryCache) TxPipeline(ctx context.Context, fn func(Pipe) error) error {
+	p := &memoryPipe{ctx: ctx}
+	if err := fn(p); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, op := range p.ops {
+		op(m, now)
+	}
+	return nil
+}
+
+// memoryPipe queues operations whose results are filled in on execution
+type memoryPipe struct {
+	ctx context.Context
+	ops []func(*memoryCache, time.Time)
+}
+
+func (p *memoryPipe) Set(key, value string, ttl time.Duration) CacheResult {
+	exp, err := expiration(ttl)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+
+	cmd := redis.NewStatusCmd(p.ctx)
+	p.ops = append(p.ops, func(m *memoryCache, now time.Time) {
+		m.set(key, value, exp, now)
+		cmd.SetVal("OK")
+	})
+	return cmd
+}
+
+func (p *memoryPipe) Get(key string) CacheResult {
+	cmd := redis.NewStringCmd(p.ctx)
+	p.ops = append(p.ops, func(m *memoryCache, now time.Time) {
+		if value, ok := m.get(key, now); ok {
+			cmd.SetVal(value)
+			return
+		}
+		cmd.SetErr(redis.Nil)
+	})
+	return cmd
+}
+
+func (p *memoryPipe) Delete(key string) CacheResult {
+	cmd := redis.NewIntCmd(p.ctx)
+	p.ops = append(p.ops, func(m *memoryCache, now time.Time) {
+		cmd.SetVal(m.del(key, now))
+	})
+	return &DelCacheResult{cmd: cmd}
+}
+
+// globMatch matches key against a Redis style glob pattern: * ? [abc] [^a] [a-z]
+// and \ to escape
+func globMatch(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if globMatch(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			end := 1
+			for end < len(pattern) && pattern[end] != ']' {
+				if pattern[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(pattern) || !classMatch(pattern[1:end], key[0]) {
+				return false
+			}
+			pattern = pattern[end:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+		}
+		pattern, key = pattern[1:], key[1:]
+	}
+	return len(key) == 0
+}
+
+func classMatch(class string, c byte) bool {
+	negate := len(class) > 0 && class[0] == '^'
+	if negate {
+		class = class[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(class); i++ {
+		switch {
+		case class[i] == '\\' && i+1 < len(class):
+			i++
+			matched = matched || class[i] == c
+		case i+2 < len(class) && class[i+1] == '-':
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || c >= lo && c <= hi
+			i += 2
+		default:
+			matched = matched || class[i] == c
+		}
+	}
+	return matched != negate
+}
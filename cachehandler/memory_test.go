@@ -0,0 +1,232 @@
+package cachehandler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "user:1", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users:1", false},
+		{"user:*:name", "user:1:name", true},
+		{"user:*:name", "user:1:email", false},
+		{"**", "a", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"h[c-a]llo", "hbllo", true},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"h[\\]]llo", "h]llo", true},
+		{"h[ae", "ha", false},
+		{"exact", "exact", true},
+		{"exact", "exact!", false},
+		{"", "", true},
+		{"", "a", false},
+	}
+
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %t, want %t", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Memory
+		writes  []string
+		reads   []string
+		then    []string
+		present []string
+		evicted []string
+	}{
+		{
+			name:    "max entries evicts the oldest",
+			cfg:     Memory{MaxEntries: 2},
+			writes:  []string{"a", "b", "c"},
+			present: []string{"b", "c"},
+			evicted: []string{"a"},
+		},
+		{
+			name:    "a read makes an entry recent",
+			cfg:     Memory{MaxEntries: 2},
+			writes:  []string{"a", "b"},
+			reads:   []string{"a"},
+			then:    []string{"c"},
+			present: []string{"a", "c"},
+			evicted: []string{"b"},
+		},
+		{
+			name:    "max bytes evicts until it fits",
+			cfg:     Memory{MaxBytes: 2 * (entryOverhead + 2)},
+			writes:  []string{"a", "b", "c"},
+			present: []string{"b", "c"},
+			evicted: []string{"a"},
+		},
+		{
+			name:    "the newest entry is kept over the bounds",
+			cfg:     Memory{MaxBytes: 1},
+			writes:  []string{"a", "b"},
+			present: []string{"b"},
+			evicted: []string{"a"},
+		},
+		{
+			name:    "unbounded",
+			writes:  []string{"a", "b", "c"},
+			present: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMemoryCache(tt.cfg, nil)
+			now := time.Now()
+			for _, key := range tt.writes {
+				m.set(key, "v", 0, now)
+			}
+			for _, key := range tt.reads {
+				m.get(key, now)
+			}
+			for _, key := range tt.then {
+				m.set(key, "v", 0, now)
+			}
+			for _, key := range tt.present {
+				if _, ok := m.get(key, now); !ok {
+					t.Errorf("%q was evicted", key)
+				}
+			}
+			for _, key := range tt.evicted {
+				if _, ok := m.get(key, now); ok {
+					t.Errorf("%q was not evicted", key)
+				}
+			}
+			if m.ll.Len() != len(m.items) {
+				t.Errorf("list has %d entries, map %d", m.ll.Len(), len(m.items))
+			}
+		})
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	m := newMemoryCache(Memory{}, nil)
+	now := time.Now()
+
+	m.set("short", "v", time.Second, now)
+	m.set("forever", "v", 0, now)
+
+	if _, ok := m.get("short", now.Add(time.Second-time.Millisecond)); !ok {
+		t.Error("short expired before its ttl")
+	}
+	if _, ok := m.get("short", now.Add(time.Second)); ok {
+		t.Error("short did not expire at its ttl")
+	}
+	if _, ok := m.items["short"]; ok {
+		t.Error("expired entry was not dropped")
+	}
+	if _, ok := m.get("forever", now.Add(24*time.Hour)); !ok {
+		t.Error("entry without ttl expired")
+	}
+
+	m.set("kept", "v", time.Second, now)
+	m.set("kept", "w", redis.KeepTTL, now.Add(500*time.Millisecond))
+	if _, ok := m.get("kept", now.Add(time.Second)); ok {
+		t.Error("KeepTTL reset the expiry")
+	}
+
+	ctx := context.Background()
+	m.SetWithTTL(ctx, "live", "v", time.Hour)
+	ttl, err := m.TTL(ctx, "live")
+	if err != nil || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL = %v, %v, want up to an hour", ttl, err)
+	}
+	if _, err := m.TTL(ctx, "missing"); !IsMiss(err) {
+		t.Errorf("TTL of a missing key = %v, want a miss", err)
+	}
+	if ok, _ := m.Persist(ctx, "live"); !ok {
+		t.Error("Persist did not remove the ttl")
+	}
+	if ttl, _ := m.TTL(ctx, "live"); ttl != NoExpiry {
+		t.Errorf("TTL after Persist = %v, want NoExpiry", ttl)
+	}
+}
+
+func TestTieredCacheApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		dropped []string
+		kept    []string
+	}{
+		{"key", invalidateKey + "user:1", []string{"user:1"}, []string{"user:2", "order:1"}},
+		{"pattern", invalidatePattern + "user:*", []string{"user:1", "user:2"}, []string{"order:1"}},
+		{"unknown payload", "x:user:1", nil, []string{"user:1", "user:2", "order:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := &tieredCache{local: newMemoryCache(Memory{}, nil)}
+			now := time.Now()
+			for _, key := range []string{"user:1", "user:2", "order:1"} {
+				tc.local.set(key, "v", 0, now)
+			}
+
+			tc.apply(tt.payload)
+
+			for _, key := range tt.dropped {
+				if _, ok := tc.local.get(key, now); ok {
+					t.Errorf("%q was not invalidated", key)
+				}
+			}
+			for _, key := range tt.kept {
+				if _, ok := tc.local.get(key, now); !ok {
+					t.Errorf("%q was invalidated", key)
+				}
+			}
+		})
+	}
+}
+
+func TestTieredCacheFill(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(*tieredCache)
+		kept       bool
+	}{
+		{"no invalidation", func(*tieredCache) {}, true},
+		{"key invalidated", func(tc *tieredCache) { tc.apply(invalidateKey + "user:1") }, false},
+		{"pattern invalidated", func(tc *tieredCache) { tc.apply(invalidatePattern + "order:*") }, false},
+		{"local write", func(tc *tieredCache) { tc.drop(context.Background(), "user:1") }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := &tieredCache{local: newMemoryCache(Memory{}, nil)}
+			ctx := context.Background()
+
+			gen := tc.generation("user:1")
+			tt.invalidate(tc)
+			tc.fill(ctx, "user:1", "stale", time.Minute, gen)
+
+			if _, ok := tc.local.get("user:1", time.Now()); ok != tt.kept {
+				t.Errorf("value kept locally = %t, want %t", ok, tt.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package cachehandler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultStreamCount     = 10
+	defaultStreamBlock     = 5 * time.Second
+	defaultClaimIdle       = time.Minute
+	defaultClaimInterval   = 30 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// Messenger publishes and consumes messages over the Redis connection of a
+// cache handler
+type Messenger interface {
+	Publish(context.Context, string, string) (int64, error)
+	Subscribe(context.Context, func(context.Context, *redis.Message), ...string) (Subscription, error)
+	XAdd(context.Context, string, map[string]interface{}, int64) (string, error)
+	Consume(context.Context, StreamConfig, StreamHandler) error
+}
+
+// Subscription is a running Subscribe. go-redis reconnects and subscribes
+// again to the same channels when the connection drops.
+type Subscription interface {
+	Close() error
+}
+
+// StreamConfig configures Consume. Messages pending for longer than ClaimIdle,
+// e.g. of a crashed consumer, are claimed every ClaimInterval. Once delivered
+// MaxDeliveries times a message is moved to DeadLetterStream, if set, and acked.
+type StreamConfig struct {
+	Stream           string `validate:"required"`
+	Group            string `validate:"required"`
+	Consumer         string `validate:"required"`
+	Count            int64
+	Block            time.Duration
+	ClaimIdle        time.Duration
+	ClaimInterval    time.Duration
+	MaxDeliveries    int64
+	DeadLetterStream string
+	ShutdownTimeout  time.Duration
+}
+
+// StreamHandler handles a stream message; the message is acked when it returns nil
+type StreamHandler func(context.Context, redis.XMessage) error
+
+type messenger struct {
+	log    logger.Logger
+	client redis.UniversalClient
+}
+
+type subscription struct {
+	pubsub *redis.PubSub
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewMessenger shares the connection of a Redis backed cache handler
+func NewMessenger(cache CacheHandler, log logger.Logger) (Messenger, error) {
+	switch c := unwrap(cache).(type) {
+	case *cacheHandler:
+		return &messenger{log: log, client: c.client}, nil
+	case *tieredCache:
+		return &messenger{log: log, client: c.remote.client}, nil
+	default:
+		return nil, errors.New("messenger needs a redis cache handler")
+	}
+}
+
+func (m *messenger) Publish(ctx context.Context, channel, message string) (int64, error) {
+	return m.client.Publish(ctx, channel, message).Result()
+}
+
+// Subscribe calls handler for every message received on channels until the
+// subscription is closed or ctx is done
+func (m *messenger) Subscribe(ctx context.Context, handler func(context.Context, *redis.Message), channels ...string) (Subscription, error) {
+	pubsub := m.client.Subscribe(ctx, channels...)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	s := &subscription{pubsub: pubsub, done: make(chan struct{})}
+	go func() {
+		defer s.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				handler(ctx, msg)
+			}
+		}
+	}()
+	return s, nil
+}
+
+func (s *subscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		err = s.pubsub.Close()
+	})
+	return err
+}
+
+// XAdd appends values to stream, trimming it to about maxLen entries when
+// maxLen is positive, and returns the message id
+func (m *messenger) XAdd(ctx context.Context, stream string, values map[string]interface{}, maxLen int64) (string, error) {
+	args := &redis.XAddArgs{Stream: stream, Values: values}
+	if maxLen > 0 {
+		args.MaxLen = maxLen
+		args.Approx = true
+	}
+	return m.client.XAdd(ctx, args).Result()
+}
+
+// Consume reads the stream as a member of the consumer group, creating the
+// group if needed, and passes every message to handler until ctx is done. It
+// then waits up to ShutdownTimeout for the message being handled.
+func (m *messenger) Consume(ctx context.Context, cfg StreamConfig, handler StreamHandler) error {
+	cfg = streamDefaults(cfg)
+
+	err := m.client.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	// handlers keep running for ShutdownTimeout once ctx is done
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+	defer cancelHandlers()
+	go func() {
+		select {
+		case <-ctx.Done():
+			timer := time.NewTimer(cfg.ShutdownTimeout)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				cancelHandlers()
+			case <-handlerCtx.Done():
+			}
+		case <-handlerCtx.Done():
+		}
+	}()
+
+	m.log.Infof("Consuming stream %s as %s/%s", cfg.Stream, cfg.Group, cfg.Consumer)
+	lastClaim := time.Time{}
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= cfg.ClaimInterval {
+			lastClaim = time.Now()
+			claimed, err := m.claim(ctx, cfg)
+			if err != nil && ctx.Err() == nil {
+				m.log.Errorf("Claiming pending messages of %s failed: %v", cfg.Stream, err)
+			}
+			m.handle(handlerCtx, cfg, handler, claimed)
+		}
+
+		streams, err := m.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    cfg.Group,
+			Consumer: cfg.Consumer,
+			Streams:  []string{cfg.Stream, ">"},
+			Count:    cfg.Count,
+			Block:    cfg.Block,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			m.log.Errorf("Reading stream %s failed: %v", cfg.Stream, err)
+			sleep(ctx, time.Second)
+			continue
+		}
+
+		for _, stream := range streams {
+			m.handle(handlerCtx, cfg, handler, stream.Messages)
+		}
+	}
+
+	m.log.Infof("Stopped consuming stream %s as %s/%s", cfg.Stream, cfg.Group, cfg.Consumer)
+	return nil
+}
+
+func streamDefaults(cfg StreamConfig) StreamConfig {
+	if cfg.Count <= 0 {
+		cfg.Count = defaultStreamCount
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = defaultStreamBlock
+	}
+	if cfg.ClaimIdle <= 0 {
+		cfg.ClaimIdle = defaultClaimIdle
+	}
+	if cfg.ClaimInterval <= 0 {
+		cfg.ClaimInterval = defaultClaimInterval
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	return cfg
+}
+
+// handle runs handler on each message and acks the successful ones; failed
+// ones stay pending and are claimed again once idle
+func (m *messenger) handle(ctx context.Context, cfg StreamConfig, handler StreamHandler, messages []redis.XMessage) {
+	for _, msg := range messages {
+		if err := handler(ctx, msg); err != nil {
+			m.log.Warnf("Handling message %s of %s failed: %v", msg.ID, cfg.Stream, err)
+			continue
+		}
+		if err := m.client.XAck(context.Background(), cfg.Stream, cfg.Group, msg.ID).Err(); err != nil {
+			m.log.Errorf("Acking message %s of %s failed: %v", msg.ID, cfg.Stream, err)
+		}
+	}
+}
+
+// claim takes over the messages idle for longer than ClaimIdle and dead-letters
+// the ones delivered too many times
+func (m *messenger) claim(ctx context.Context, cfg StreamConfig) ([]redis.XMessage, error) {
+	pending, err := m.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: cfg.Stream,
+		Group:  cfg.Group,
+		Idle:   cfg.ClaimIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  cfg.Count,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	var ids, dead []string
+	for _, p := range pending {
+		if cfg.MaxDeliveries > 0 && p.RetryCount >= cfg.MaxDeliveries {
+			dead = append(dead, p.ID)
+			continue
+		}
+		ids = append(ids, p.ID)
+	}
+
+	if len(dead) > 0 {
+		if err := m.deadLetter(ctx, cfg, dead); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	return m.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   cfg.Stream,
+		Group:    cfg.Group,
+		Consumer: cfg.Consumer,
+		MinIdle:  cfg.ClaimIdle,
+		Messages: ids,
+	}).Result()
+}
+
+func (m *messenger) deadLetter(ctx context.Context, cfg StreamConfig, ids []string) error {
+	messages, err := m.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   cfg.Stream,
+		Group:    cfg.Group,
+		Consumer: cfg.Consumer,
+		MinIdle:  cfg.ClaimIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		m.log.Errorf("Message %s of %s dead-lettered after %d deliveries", msg.ID, cfg.Stream, cfg.MaxDeliveries)
+		if cfg.DeadLetterStream != "" {
+			values := make(map[string]interface{}, len(msg.Values)+1)
+			for k, v := range msg.Values {
+				values[k] = v
+			}
+			values["original_id"] = msg.ID
+			if err := m.client.XAdd(ctx, &redis.XAddArgs{Stream: cfg.DeadLetterStream, Values: values}).Err(); err != nil {
+				return err
+			}
+		}
+		if err := m.client.XAck(ctx, cfg.Stream, cfg.Group, msg.ID).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
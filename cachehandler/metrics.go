@@ -0,0 +1,284 @@
+package cachehandler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Outcome labels a cache operation recorded by Metrics
+type Outcome string
+
+const (
+	OutcomeHit   Outcome = "hit"
+	OutcomeMiss  Outcome = "miss"
+	OutcomeOK    Outcome = "ok"
+	OutcomeError Outcome = "error"
+)
+
+// Metrics records cache operations. Reads are a hit or a miss, other
+// operations ok or error. Batch operations record every key with the latency
+// of the whole call.
+type Metrics interface {
+	Observe(op, prefix string, outcome Outcome, elapsed time.Duration)
+}
+
+// KeyPrefix is the default prefix of a key in metrics, the part before the
+// first colon, or "" for a key without one
+func KeyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// PoolStats returns the connection pool stats of a Redis backed handler
+func PoolStats(cache CacheHandler) (*redis.PoolStats, bool) {
+	switch c := unwrap(cache).(type) {
+	case *cacheHandler:
+		return c.client.PoolStats(), true
+	case *tieredCache:
+		return c.remote.client.PoolStats(), true
+	default:
+		return nil, false
+	}
+}
+
+// instrumentedCache records every call to the wrapped handler in metrics
+type instrumentedCache struct {
+	cache   CacheHandler
+	metrics Metrics
+	prefix  func(string) string
+}
+
+// NewInstrumentedCacheHandler records the operations of cache in metrics,
+// labelled with the prefix of their key. prefix defaults to KeyPrefix and
+// should keep the number of distinct prefixes small.
+func NewInstrumentedCacheHandler(cache CacheHandler, metrics Metrics, prefix func(string) string) CacheHandler {
+	if prefix == nil {
+		prefix = KeyPrefix
+	}
+	return &instrumentedCache{cache: cache, metrics: metrics, prefix: prefix}
+}
+
+// unwrap returns the handler behind the instrumentation, if any
+func unwrap(cache CacheHandler) CacheHandler {
+	if c, ok := cache.(*instrumentedCache); ok {
+		return unwrap(c.cache)
+	}
+	return cache
+}
+
+func (c *instrumentedCache) observe(op, key string, outcome Outcome, start time.Time) {
+	c.metrics.Observe(op, c.prefix(key), outcome, time.Since(start))
+}
+
+func outcome(err error) Outcome {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeOK
+}
+
+func readOutcome(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeHit
+	case IsMiss(err):
+		return OutcomeMiss
+	default:
+		return OutcomeError
+	}
+}
+
+func (c *instrumentedCache) observeResults(op string, results []KeyResult, err error, start time.Time, read bool) {
+	elapsed := time.Since(start)
+	for _, r := range results {
+		o := outcome(r.Err)
+		if read {
+			o = readOutcome(r.Err)
+		} else if IsMiss(r.Err) {
+			o = OutcomeOK
+		}
+		c.metrics.Observe(op, c.prefix(r.Key), o, elapsed)
+	}
+	if err != nil && len(results) == 0 {
+		c.metrics.Observe(op, "", OutcomeError, elapsed)
+	}
+}
+
+func (c *instrumentedCache) Set(key, value string, ttl int) CacheResult {
+	start := time.Now()
+	r := c.cache.Set(key, value, ttl)
+	_, err := r.Result()
+	c.observe("set", key, outcome(err), start)
+	return r
+}
+
+func (c *instrumentedCache) Get(key string) CacheResult {
+	start := time.Now()
+	r := c.cache.Get(key)
+	_, err := r.Result()
+	c.observe("get", key, readOutcome(err), start)
+	return r
+}
+
+func (c *instrumentedCache) Delete(key string) CacheResult {
+	start := time.Now()
+	r := c.cache.Delete(key)
+	_, err := r.Result()
+	c.observe("delete", key, outcome(err), start)
+	return r
+}
+
+func (c *instrumentedCache) SetContext(ctx context.Context, key, value string, ttl int) CacheResult {
+	start := time.Now()
+	r := c.cache.SetContext(ctx, key, value, ttl)
+	_, err := r.Result()
+	c.observe("set", key, outcome(err), start)
+	return r
+}
+
+func (c *instrumentedCache) GetContext(ctx context.Context, key string) CacheResult {
+	start := time.Now()
+	r := c.cache.GetContext(ctx, key)
+	_, err := r.Result()
+	c.observe("get", key, readOutcome(err), start)
+	return r
+}
+
+func (c *instrumentedCache) DeleteContext(ctx context.Context, key string) CacheResult {
+	start := time.Now()
+	r := c.cache.DeleteContext(ctx, key)
+	_, err := r.Result()
+	c.observe("delete", key, outcome(err), start)
+	return r
+}
+
+func (c *instrumentedCache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) CacheResult {
+	start := time.Now()
+	r := c.cache.SetWithTTL(ctx, key, value, ttl)
+	_, err := r.Result()
+	c.observe("set", key, outcome(err), start)
+	return r
+}
+
+func (c *instrumentedCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := c.cache.SetNX(ctx, key, value, ttl)
+	c.observe("setnx", key, outcome(err), start)
+	return ok, err
+}
+
+func (c *instrumentedCache) SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := c.cache.SetXX(ctx, key, value, ttl)
+	c.observe("setxx", key, outcome(err), start)
+	return ok, err
+}
+
+func (c *instrumentedCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := c.cache.Expire(ctx, key, ttl)
+	c.observe("expire", key, outcome(err), start)
+	return ok, err
+}
+
+func (c *instrumentedCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := c.cache.TTL(ctx, key)
+	c.observe("ttl", key, readOutcome(err), start)
+	return ttl, err
+}
+
+func (c *instrumentedCache) Persist(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	ok, err := c.cache.Persist(ctx, key)
+	c.observe("persist", key, outcome(err), start)
+	return ok, err
+}
+
+func (c *instrumentedCache) TryLock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	start := time.Now()
+	l, err := c.cache.TryLock(ctx, key, ttl)
+	o := outcome(err)
+	if errors.Is(err, ErrLockNotObtained) {
+		o = OutcomeOK
+	}
+	c.observe("lock", key, o, start)
+	return l, err
+}
+
+func (c *instrumentedCache) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	start := time.Now()
+	l, err := c.cache.Lock(ctx, key, ttl)
+	c.observe("lock", key, outcome(err), start)
+	return l, err
+}
+
+func (c *instrumentedCache) Allow(ctx context.Context, key string, limit Limit) (*RateLimitResult, error) {
+	start := time.Now()
+	r, err := c.cache.Allow(ctx, key, limit)
+	c.observe("ratelimit", key, outcome(err), start)
+	return r, err
+}
+
+func (c *instrumentedCache) MGet(ctx context.Context, keys ...string) ([]KeyResult, error) {
+	start := time.Now()
+	results, err := c.cache.MGet(ctx, keys...)
+	c.observeResults("get", results, err, start, true)
+	return results, err
+}
+
+func (c *instrumentedCache) MSet(ctx context.Context, values map[string]string, ttl time.Duration) ([]KeyResult, error) {
+	start := time.Now()
+	results, err := c.cache.MSet(ctx, values, ttl)
+	c.observeResults("set", results, err, start, false)
+	return results, err
+}
+
+func (c *instrumentedCache) DeleteMany(ctx context.Context, keys ...string) ([]KeyResult, error) {
+	start := time.Now()
+	results, err := c.cache.DeleteMany(ctx, keys...)
+	c.observeResults("delete", results, err, start, false)
+	return results, err
+}
+
+func (c *instrumentedCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	start := time.Now()
+	n, err := c.cache.DeleteByPattern(ctx, pattern)
+	c.observe("delete_pattern", pattern, outcome(err), start)
+	return n, err
+}
+
+func (c *instrumentedCache) Pipeline(ctx context.Context, fn func(Pipe) error) error {
+	start := time.Now()
+	err := c.cache.Pipeline(ctx, fn)
+	c.metrics.Observe("pipeline", "", outcome(err), time.Since(start))
+	return err
+}
+
+func (c *instrumentedCache) TxPipeline(ctx context.Context, fn func(Pipe) error) error {
+	start := time.Now()
+	err := c.cache.TxPipeline(ctx, fn)
+	c.metrics.Observe("tx_pipeline", "", outcome(err), time.Since(start))
+	return err
+}
+
+func (c *instrumentedCache) SetWithTags(ctx context.Context, key, value string, ttl time.Duration, tags ...string) CacheResult {
+	start := time.Now()
+	r := c.cache.SetWithTags(ctx, key, value, ttl, tags...)
+	_, err := r.Result()
+	c.observe("set", key, outcome(err), start)
+	return r
+}
+
+func (c *instrumentedCache) InvalidateTags(ctx context.Context, tags ...string) (int64, error) {
+	start := time.Now()
+	n, err := c.cache.InvalidateTags(ctx, tags...)
+	c.metrics.Observe("invalidate_tags", "", outcome(err), time.Since(start))
+	return n, err
+}
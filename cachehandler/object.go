@@ -0,0 +1,266 @@
+package cachehandler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Flags of the header byte in front of every value written by ObjectCache
+const (
+	flagGzip       byte = 1 << 0
+	flagFreshUntil byte = 1 << 1
+	flagNotFound   byte = 1 << 2
+)
+
+// ErrMiss is returned by ObjectCache when the key does not exist
+var ErrMiss = errors.New("cache miss")
+
+// IsMiss reports whether err is a cache miss, from ObjectCache or a CacheResult
+func IsMiss(err error) bool {
+	return errors.Is(err, ErrMiss) || errors.Is(err, redis.Nil)
+}
+
+// ObjectCache stores values of type T on top of a CacheHandler. Values larger
+// than CompressThreshold bytes once encoded are gzipped; 0 disables compression.
+type ObjectCache[T any] struct {
+	cache             CacheHandler
+	codec             Codec
+	compressThreshold int
+	loads             flight
+}
+
+// LoadOptions tune GetOrLoad. A value is fresh for TTL, then served for up to
+// StaleTTL more while it is reloaded in the background. It is also reloaded in
+// the background when less than RefreshAhead of its TTL is left. NegativeTTL
+// caches not found results, 0 disables it. Jitter adds up to that fraction of
+// the ttl at random, so keys written together do not expire together.
+type LoadOptions struct {
+	TTL          time.Duration
+	StaleTTL     time.Duration
+	RefreshAhead time.Duration
+	NegativeTTL  time.Duration
+	Jitter       float64
+}
+
+// entry is a decoded cached value with its metadata
+type entry[T any] struct {
+	value      T
+	notFound   bool
+	freshUntil time.Time
+}
+
+func NewObjectCache[T any](cache CacheHandler, codec Codec, compressThreshold int) *ObjectCache[T] {
+	if codec == nil {
+		codec = JSONCodec
+	}
+	return &ObjectCache[T]{
+		cache:             cache,
+		codec:             codec,
+		compressThreshold: compressThreshold,
+	}
+}
+
+func (o *ObjectCache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := o.encode(entry[T]{value: value})
+	if err != nil {
+		return err
+	}
+	_, err = o.cache.SetWithTTL(ctx, key, string(data), ttl).Result()
+	return err
+}
+
+// Get returns ErrMiss when the key does not exist or holds a cached not found,
+// other errors come from Redis or from decoding
+func (o *ObjectCache[T]) Get(ctx context.Context, key string) (T, error) {
+	e, err := o.get(ctx, key)
+	if err != nil {
+		return e.value, err
+	}
+	if e.notFound {
+		return e.value, ErrMiss
+	}
+	return e.value, nil
+}
+
+// GetOrLoad returns the cached value or calls loader on a miss and caches its
+// result. Concurrent loads of the same key in the process share one loader
+// call, made on a context detached from the callers' cancellation. A loader
+// error of type errs.NotFound, or sql.ErrNoRows, is returned as errs.NotFound
+// and cached for NegativeTTL.
+func (o *ObjectCache[T]) GetOrLoad(ctx context.Context, key string, loader func(context.Context) (T, error), opts LoadOptions) (T, error) {
+	e, err := o.get(ctx, key)
+	if err == nil {
+		now := time.Now()
+		switch {
+		case e.freshUntil.IsZero() || now.Add(opts.RefreshAhead).Before(e.freshUntil):
+		case now.Before(e.freshUntil.Add(opts.StaleTTL)):
+			o.refresh(key, loader, opts)
+		default:
+			e, err = o.load(ctx, key, loader, opts)
+		}
+	} else {
+		e, err = o.load(ctx, key, loader, opts)
+	}
+
+	if err == nil && e.notFound {
+		err = errs.NotFound.Errorf("%s not found", key)
+	}
+	return e.value, err
+}
+
+// refresh reloads the key in the background unless a load is already running
+func (o *ObjectCache[T]) refresh(key string, loader func(context.Context) (T, error), opts LoadOptions) {
+	if o.loads.running(key) {
+		return
+	}
+	go o.load(context.Background(), key, loader, opts)
+}
+
+// load calls loader once for the concurrent callers and caches its result. A
+// not found result is an entry with notFound set, not an error.
+func (o *ObjectCache[T]) load(ctx context.Context, key string, loader func(context.Context) (T, error), opts LoadOptions) (entry[T], error) {
+	v, err := o.loads.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		value, err := loader(ctx)
+		if err != nil && !isNotFound(err) {
+			return entry[T]{}, err
+		}
+
+		e := entry[T]{value: value, notFound: err != nil}
+		ttl := opts.TTL
+		if e.notFound {
+			if opts.NegativeTTL <= 0 {
+				return e, nil
+			}
+			ttl = opts.NegativeTTL
+		}
+
+		if ttl > 0 {
+			ttl = jitter(ttl, opts.Jitter)
+			e.freshUntil = time.Now().Add(ttl)
+			ttl += opts.StaleTTL
+		} else {
+			ttl = NoExpiry
+		}
+
+		// the loaded value is returned even if it could not be cached
+		if data, encErr := o.encode(e); encErr == nil {
+			o.cache.SetWithTTL(ctx, key, string(data), ttl)
+		}
+		return e, nil
+	})
+	e, _ := v.(entry[T])
+	return e, err
+}
+
+func isNotFound(err error) bool {
+	return errs.GetType(err) == errs.NotFound || errors.Is(err, sql.ErrNoRows)
+}
+
+func jitter(ttl time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Float64()*fraction*float64(ttl))
+}
+
+func (o *ObjectCache[T]) get(ctx context.Context, key string) (entry[T], error) {
+	data, err := o.cache.GetContext(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return entry[T]{}, ErrMiss
+		}
+		return entry[T]{}, err
+	}
+	return o.decode([]byte(data))
+}
+
+// encode writes a header byte of flags, the fresh-until time when set and the
+// encoded value, gzipped above the compression threshold
+func (o *ObjectCache[T]) encode(e entry[T]) ([]byte, error) {
+	var (
+		flags byte
+		data  []byte
+		err   error
+	)
+	if e.notFound {
+		flags |= flagNotFound
+	} else if data, err = o.codec.Marshal(e.value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if !e.freshUntil.IsZero() {
+		flags |= flagFreshUntil
+	}
+	if o.compressThreshold > 0 && len(data) > o.compressThreshold {
+		flags |= flagGzip
+	}
+
+	buf.WriteByte(flags)
+	if flags&flagFreshUntil != 0 {
+		binary.Write(&buf, binary.BigEndian, e.freshUntil.UnixNano())
+	}
+	if flags&flagGzip == 0 {
+		buf.Write(data)
+		return buf.Bytes(), nil
+	}
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (o *ObjectCache[T]) decode(data []byte) (entry[T], error) {
+	var e entry[T]
+	if len(data) == 0 {
+		return e, errors.New("empty cached value")
+	}
+
+	flags, data := data[0], data[1:]
+	if flags&^(flagGzip|flagFreshUntil|flagNotFound) != 0 {
+		return e, errors.New("unknown cached value format")
+	}
+
+	if flags&flagFreshUntil != 0 {
+		if len(data) < 8 {
+			return e, errors.New("truncated cached value")
+		}
+		e.freshUntil = time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+		data = data[8:]
+	}
+	if flags&flagNotFound != 0 {
+		e.notFound = true
+		return e, nil
+	}
+
+	if flags&flagGzip != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return e, err
+		}
+		defer zr.Close()
+
+		if data, err = io.ReadAll(zr); err != nil {
+			return e, err
+		}
+	}
+
+	err := o.codec.Unmarshal(data, &e.value)
+	return e, err
+}
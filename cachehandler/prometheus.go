@@ -0,0 +1,293 @@
+package cachehandler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultBuckets are the latency buckets in seconds of PrometheusMetrics
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+type operationKey struct {
+	op     string
+	prefix string
+}
+
+type counterKey struct {
+	operationKey
+	outcome Outcome
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// PrometheusMetrics keeps the cache metrics in memory and writes them in the
+// Prometheus text exposition format. It is an http.Handler for the scrape
+// endpoint.
+type PrometheusMetrics struct {
+	namespace string
+	buckets   []float64
+
+	mu         sync.Mutex
+	counters   map[counterKey]uint64
+	histograms map[operationKey]*histogram
+	pools      map[string]CacheHandler
+}
+
+// NewPrometheusMetrics prefixes the metric names with namespace, if not
+// empty. buckets defaults to DefaultBuckets.
+func NewPrometheusMetrics(namespace string, buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	if namespace != "" {
+		namespace += "_"
+	}
+	return &PrometheusMetrics{
+		namespace:  namespace,
+		buckets:    buckets,
+		counters:   make(map[counterKey]uint64),
+		histograms: make(map[operationKey]*histogram),
+		pools:      make(map[string]CacheHandler),
+	}
+}
+
+func (p *PrometheusMetrics) Observe(op, prefix string, outcome Outcome, elapsed time.Duration) {
+	key := operationKey{op: op, prefix: prefix}
+	seconds := elapsed.Seconds()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.counters[counterKey{operationKey: key, outcome: outcome}]++
+
+	h, ok := p.histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		p.histograms[key] = h
+	}
+	for i, le := range p.buckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// RegisterPool exports the connection pool stats of a Redis backed handler
+// under the given pool label
+func (p *PrometheusMetrics) RegisterPool(name string, cache CacheHandler) error {
+	if _, ok := PoolStats(cache); !ok {
+		return fmt.Errorf("cache handler %q has no redis connection pool", name)
+	}
+	p.mu.Lock()
+	p.pools[name] = cache
+	p.mu.Unlock()
+	return nil
+}
+
+// HitRatio returns the share of reads under prefix that were hits
+func (p *PrometheusMetrics) HitRatio(prefix string) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := p.reads()[prefix]
+	if r.hits+r.misses == 0 {
+		return 0
+	}
+	return float64(r.hits) / float64(r.hits+r.misses)
+}
+
+type readCount struct {
+	hits   uint64
+	misses uint64
+}
+
+// reads sums hits and misses per prefix, p.mu must be held
+func (p *PrometheusMetrics) reads() map[string]readCount {
+	reads := make(map[string]readCount)
+	for k, v := range p.counters {
+		r := reads[k.prefix]
+		switch k.outcome {
+		case OutcomeHit:
+			r.hits += v
+		case OutcomeMiss:
+			r.misses += v
+		default:
+			continue
+		}
+		reads[k.prefix] = r
+	}
+	return reads
+}
+
+// ServeHTTP writes the metrics for a Prometheus scrape
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	p.mu.Lock()
+	p.writeCounters(cw)
+	p.writeHistograms(cw)
+	p.writeHitRatios(cw)
+	pools := make(map[string]CacheHandler, len(p.pools))
+	for name, cache := range p.pools {
+		pools[name] = cache
+	}
+	p.mu.Unlock()
+
+	p.writePools(cw, pools)
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+func (p *PrometheusMetrics) writeCounters(w *countingWriter) {
+	name := p.namespace + "cache_operations_total"
+	w.printf("# HELP %s Cache operations by key prefix and outcome.\n# TYPE %s counter\n", name, name)
+
+	keys := make([]counterKey, 0, len(p.counters))
+	for k := range p.counters {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operationKey != keys[j].operationKey {
+			return keys[i].operationKey.less(keys[j].operationKey)
+		}
+		return keys[i].outcome < keys[j].outcome
+	})
+	for _, k := range keys {
+		w.printf("%s{op=%s,prefix=%s,outcome=%s} %d\n", name,
+			quote(k.op), quote(k.prefix), quote(string(k.outcome)), p.counters[k])
+	}
+}
+
+func (p *PrometheusMetrics) writeHistograms(w *countingWriter) {
+	name := p.namespace + "cache_operation_duration_seconds"
+	w.printf("# HELP %s Cache operation latency by key prefix.\n# TYPE %s histogram\n", name, name)
+
+	keys := make([]operationKey, 0, len(p.histograms))
+	for k := range p.histograms {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	for _, k := range keys {
+		h := p.histograms[k]
+		labels := fmt.Sprintf("op=%s,prefix=%s", quote(k.op), quote(k.prefix))
+		for i, le := range p.buckets {
+			w.printf("%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(le), h.counts[i])
+		}
+		w.printf("%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		w.printf("%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		w.printf("%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func (p *PrometheusMetrics) writeHitRatios(w *countingWriter) {
+	name := p.namespace + "cache_hit_ratio"
+	w.printf("# HELP %s Share of cache reads that were hits, by key prefix.\n# TYPE %s gauge\n", name, name)
+
+	reads := p.reads()
+	prefixes := make([]string, 0, len(reads))
+	for prefix := range reads {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		r := reads[prefix]
+		w.printf("%s{prefix=%s} %s\n", name, quote(prefix), formatFloat(float64(r.hits)/float64(r.hits+r.misses)))
+	}
+}
+
+func (p *PrometheusMetrics) writePools(w *countingWriter, pools map[string]CacheHandler) {
+	if len(pools) == 0 {
+		return
+	}
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type poolMetric struct {
+		name, help, kind string
+		value            func(stats *redis.PoolStats) uint64
+	}
+	metrics := []poolMetric{
+		{"redis_pool_hits_total", "Free connections found in the pool.", "counter", func(s *redis.PoolStats) uint64 { return uint64(s.Hits) }},
+		{"redis_pool_misses_total", "Free connections not found in the pool.", "counter", func(s *redis.PoolStats) uint64 { return uint64(s.Misses) }},
+		{"redis_pool_timeouts_total", "Waits for a connection that timed out.", "counter", func(s *redis.PoolStats) uint64 { return uint64(s.Timeouts) }},
+		{"redis_pool_total_conns", "Connections in the pool.", "gauge", func(s *redis.PoolStats) uint64 { return uint64(s.TotalConns) }},
+		{"redis_pool_idle_conns", "Idle connections in the pool.", "gauge", func(s *redis.PoolStats) uint64 { return uint64(s.IdleConns) }},
+		{"redis_pool_stale_conns", "Stale connections removed from the pool.", "counter", func(s *redis.PoolStats) uint64 { return uint64(s.StaleConns) }},
+	}
+
+	stats := make([]*redis.PoolStats, len(names))
+	for i, name := range names {
+		stats[i], _ = PoolStats(pools[name])
+	}
+	for _, m := range metrics {
+		name := p.namespace + m.name
+		w.printf("# HELP %s %s\n# TYPE %s %s\n", name, m.help, name, m.kind)
+		for i, pool := range names {
+			w.printf("%s{pool=%s} %d\n", name, quote(pool), m.value(stats[i]))
+		}
+	}
+}
+
+func (k operationKey) less(o operationKey) bool {
+	if k.op != o.op {
+		return k.op < o.op
+	}
+	return k.prefix < o.prefix
+}
+
+// quote escapes a label value
+func quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countingWriter keeps the first write error, so the exposition can be
+// written without checking every line
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
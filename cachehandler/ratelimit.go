@@ -0,0 +1,130 @@
+package cachehandler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RateLimitAlgorithm int
+
+const (
+	// SlidingWindow allows Rate requests in any window of Period
+	SlidingWindow RateLimitAlgorithm = iota
+	// TokenBucket refills Rate tokens per Period up to Burst
+	TokenBucket
+)
+
+// Limit configures Allow. Burst is only used by TokenBucket and defaults to Rate.
+type Limit struct {
+	Algorithm RateLimitAlgorithm
+	Rate      int
+	Period    time.Duration
+	Burst     int
+}
+
+// RateLimitResult is the outcome of one Allow call. RetryAfter is set when the
+// request was denied, ResetAfter is when the limit is fully available again.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+var (
+	slidingWindowScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, now .. ":" .. ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+local newest = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+local reset = tonumber(newest[2]) + window - now
+if allowed == 0 then
+	return {0, 0, tonumber(oldest[2]) + window - now, reset}
+end
+return {1, limit - count, 0, reset}`)
+
+	tokenBucketScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+return {allowed, math.floor(tokens), retry, math.ceil((burst - tokens) / rate)}`)
+)
+
+// Allow counts one request against key and reports whether it fits the limit
+func (c *cacheHandler) Allow(ctx context.Context, key string, limit Limit) (*RateLimitResult, error) {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return nil, errors.New("rate limit needs a positive rate and period")
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var (
+		res []int64
+		err error
+		max = limit.Rate
+	)
+	switch limit.Algorithm {
+	case TokenBucket:
+		if limit.Burst > 0 {
+			max = limit.Burst
+		}
+		perMs := float64(limit.Rate) / float64(limit.Period.Milliseconds())
+		res, err = tokenBucketScript.Run(ctx, c.client, []string{key}, perMs, max).Int64Slice()
+	default:
+		token, tokenErr := lockToken()
+		if tokenErr != nil {
+			return nil, tokenErr
+		}
+		res, err = slidingWindowScript.Run(ctx, c.client, []string{key}, limit.Period.Milliseconds(), limit.Rate, token).Int64Slice()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 4 {
+		return nil, errors.New("unexpected rate limit script reply")
+	}
+
+	return &RateLimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      max,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		ResetAfter: time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,81 @@
+package cachehandler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// call is an in-flight or finished flight.do call
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// flight deduplicates concurrent calls for the same key within the process
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do runs fn once for all the callers asking for key at the same time. fn runs
+// on a context detached from the callers, so one of them giving up does not
+// fail the others; each caller waits for the shared result or its own ctx.
+func (f *flight) do(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]*call)
+	}
+	c, ok := f.calls[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		f.calls[key] = c
+		go f.run(detach(ctx), key, c, fn)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (f *flight) run(ctx context.Context, key string, c *call, fn func(context.Context) (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("cache load panicked: %v", r)
+		}
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn(ctx)
+}
+
+// running reports whether a call for key is in flight
+func (f *flight) running(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.calls[key]
+	return ok
+}
+
+// detachedContext keeps the values of its parent but not its deadline or
+// cancellation, like context.WithoutCancel
+type detachedContext struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
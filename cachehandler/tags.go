@@ -0,0 +1,207 @@
+package cachehandler
+
+import (
+	"context"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tagPrefix prefixes the Redis sets holding the keys of a tag
+const tagPrefix = "tag:"
+
+var (
+	setWithTagsScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+
+for i = 2, #KEYS do
+	local created = redis.call("EXISTS", KEYS[i]) == 0
+	redis.call("SADD", KEYS[i], KEYS[1])
+	if ttl == 0 then
+		redis.call("PERSIST", KEYS[i])
+	else
+		local current = redis.call("PTTL", KEYS[i])
+		if created or (current >= 0 and current < ttl) then
+			redis.call("PEXPIRE", KEYS[i], ttl)
+		end
+	end
+end
+return "OK"`)
+
+	invalidateTagsScript = redis.NewScript(`
+local deleted = {}
+for i = 1, #KEYS do
+	local members = redis.call("SMEMBERS", KEYS[i])
+	for _, key in ipairs(members) do
+		if redis.call("DEL", key) == 1 then
+			table.insert(deleted, key)
+		end
+	end
+	redis.call("DEL", KEYS[i])
+end
+return deleted`)
+)
+
+func tagKeys(tags []string) []string {
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = tagPrefix + tag
+	}
+	return keys
+}
+
+// tagTTL checks the ttl of a tagged write; tag sets live as long as their
+// longest lived key, so KeepTTL is not supported
+func tagTTL(ttl time.Duration) (time.Duration, error) {
+	if ttl == KeepTTL {
+		return 0, errs.Invalidated.New("KeepTTL can not be used with tags")
+	}
+	return expiration(ttl)
+}
+
+// SetWithTags stores value and adds key to the set of each tag, atomically.
+// On a cluster the key and the tag sets must hash to the same slot.
+func (c *cacheHandler) SetWithTags(ctx context.Context, key, value string, ttl time.Duration, tags ...string) CacheResult {
+	exp, err := tagTTL(ttl)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	keys := append([]string{key}, tagKeys(tags)...)
+	res, err := setWithTagsScript.Run(ctx, c.client, keys, value, exp.Milliseconds()).Text()
+	return redis.NewStatusResult(res, err)
+}
+
+// InvalidateTags deletes every key carrying one of the tags, and the tags. It
+// returns how many keys were deleted. It is atomic except on a cluster, where
+// the keys of a tag may live in other slots than its set.
+func (c *cacheHandler) InvalidateTags(ctx context.Context, tags ...string) (int64, error) {
+	deleted, err := c.invalidateTags(ctx, tags...)
+	return int64(len(deleted)), err
+}
+
+func (c *cacheHandler) invalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	if _, ok := c.client.(*redis.ClusterClient); ok {
+		return c.invalidateClusterTags(ctx, tags)
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return invalidateTagsScript.Run(ctx, c.client, tagKeys(tags)).StringSlice()
+}
+
+// invalidateClusterTags reads the tag sets and deletes their keys with a
+// pipeline, which go-redis splits by node, as a script may only touch keys of
+// its own slot. A key tagged while it runs may survive.
+func (c *cacheHandler) invalidateClusterTags(ctx context.Context, tags []string) ([]string, error) {
+	sets := tagKeys(tags)
+	members := make([]*redis.StringSliceCmd, len(sets))
+	err := c.pipelined(ctx, false, func(p redis.Pipeliner) {
+		for i, set := range sets {
+			members[i] = p.SMembers(ctx, set)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, cmd := range members {
+		for _, key := range cmd.Val() {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	dels := make([]*redis.IntCmd, len(keys))
+	err = c.pipelined(ctx, false, func(p redis.Pipeliner) {
+		for i, key := range keys {
+			dels[i] = p.Del(ctx, key)
+		}
+		for _, set := range sets {
+			p.Del(ctx, set)
+		}
+	})
+
+	var deleted []string
+	for i, cmd := range dels {
+		if cmd.Val() == 1 {
+			deleted = append(deleted, keys[i])
+		}
+	}
+	return deleted, err
+}
+
+func (m *memoryCache) SetWithTags(ctx context.Context, key, value string, ttl time.Duration, tags ...string) CacheResult {
+	exp, err := tagTTL(ttl)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// the tags live on the entry, so they go away with it when it is
+	// overwritten, deleted, expired or evicted
+	m.set(key, value, exp, time.Now())
+	m.tag(m.items[key].Value.(*memoryEntry), append([]string(nil), tags...))
+	return redis.NewStatusResult("OK", nil)
+}
+
+// tag sets the tags of a stored entry. mu must be held.
+func (m *memoryCache) tag(e *memoryEntry, tags []string) {
+	e.tags = tags
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][e.key] = struct{}{}
+	}
+}
+
+func (m *memoryCache) InvalidateTags(ctx context.Context, tags ...string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for _, tag := range tags {
+		keys := make([]string, 0, len(m.tags[tag]))
+		for key := range m.tags[tag] {
+			keys = append(keys, key)
+		}
+		for _, key := range keys {
+			deleted += m.del(key, now)
+		}
+		delete(m.tags, tag)
+	}
+	return deleted, nil
+}
+
+func (t *tieredCache) SetWithTags(ctx context.Context, key, value string, ttl time.Duration, tags ...string) CacheResult {
+	res := t.remote.SetWithTags(ctx, key, value, ttl, tags...)
+	t.invalidate(ctx, key)
+	return res
+}
+
+func (t *tieredCache) InvalidateTags(ctx context.Context, tags ...string) (int64, error) {
+	deleted, err := t.remote.invalidateTags(ctx, tags...)
+	t.invalidate(ctx, deleted...)
+	return int64(len(deleted)), err
+}
@@ -0,0 +1,399 @@
+package cachehandler
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultLocalTTL = time.Minute
+	defaultChannel  = "cachehandler:invalidate"
+
+	invalidateKey     = "k:"
+	invalidatePattern = "p:"
+
+	generationBuckets = 256
+)
+
+// tieredCache keeps hot values in a local memoryCache (L1) in front of Redis
+// (L2). Every write goes to Redis, drops the local copy and is broadcast on
+// the invalidation channel, so the other instances drop theirs.
+type tieredCache struct {
+	log      logger.Logger
+	local    *memoryCache
+	remote   *cacheHandler
+	localTTL time.Duration
+	channel  string
+
+	// generations count the invalidations per bucket of keys and of patterns,
+	// a value read from Redis is only kept locally if none happened meanwhile
+	genMu      sync.Mutex
+	keyGens    [generationBuckets]uint64
+	patternGen uint64
+}
+
+// NewTieredCacheHandler puts an in-process cache in front of remote, which
+// must come from NewCacheHandler or ConnectCacheHandler. Invalidations are
+// received until ctx is done.
+func NewTieredCacheHandler(ctx context.Context, remote CacheHandler, cfg Memory, log logger.Logger) (CacheHandler, error) {
+	r, ok := unwrap(remote).(*cacheHandler)
+	if !ok {
+		return nil, errors.New("tiered cache needs a redis cache handler")
+	}
+
+	t := &tieredCache{
+		log:      log,
+		local:    newMemoryCache(cfg, log),
+		remote:   r,
+		localTTL: cfg.GetLocalTTL(),
+		channel:  cfg.GetChannel(),
+	}
+	if t.localTTL <= 0 {
+		t.localTTL = defaultLocalTTL
+	}
+	if t.channel == "" {
+		t.channel = defaultChannel
+	}
+
+	sub := r.client.Subscribe(ctx, t.channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	go t.listen(ctx, sub)
+
+	return t, nil
+}
+
+// listen applies invalidations from the other instances. The local cache is
+// flushed on every resubscription, invalidations may have been missed while
+// the connection was down.
+func (t *tieredCache) listen(ctx context.Context, sub *redis.PubSub) {
+	defer sub.Close()
+
+	messages := sub.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			switch m := msg.(type) {
+			case *redis.Subscription:
+				if m.Kind == "subscribe" {
+					t.log.Warn("Tiered cache resubscribed, flushing local cache")
+					t.dropPattern(ctx, "*")
+				}
+			case *redis.Message:
+				t.apply(m.Payload)
+			}
+		}
+	}
+}
+
+func (t *tieredCache) apply(payload string) {
+	ctx := context.Background()
+	switch {
+	case strings.HasPrefix(payload, invalidateKey):
+		t.drop(ctx, strings.TrimPrefix(payload, invalidateKey))
+	case strings.HasPrefix(payload, invalidatePattern):
+		t.dropPattern(ctx, strings.TrimPrefix(payload, invalidatePattern))
+	}
+}
+
+func generationBucket(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % generationBuckets)
+}
+
+// generation returns the invalidation generation of key. It only grows.
+func (t *tieredCache) generation(key string) uint64 {
+	t.genMu.Lock()
+	defer t.genMu.Unlock()
+	return t.generationLocked(key)
+}
+
+func (t *tieredCache) generationLocked(key string) uint64 {
+	return t.keyGens[generationBucket(key)] + t.patternGen
+}
+
+// drop bumps the generation of keys and removes them from the local cache
+func (t *tieredCache) drop(ctx context.Context, keys ...string) {
+	t.genMu.Lock()
+	for _, key := range keys {
+		t.keyGens[generationBucket(key)]++
+	}
+	t.genMu.Unlock()
+	t.local.DeleteMany(ctx, keys...)
+}
+
+func (t *tieredCache) dropPattern(ctx context.Context, pattern string) {
+	t.genMu.Lock()
+	t.patternGen++
+	t.genMu.Unlock()
+	t.local.DeleteByPattern(ctx, pattern)
+}
+
+// fill keeps a value read from Redis locally unless key was invalidated since
+// gen was taken. Holding genMu orders it with drop: an invalidation either
+// comes first and skips the fill, or comes after and removes the value.
+func (t *tieredCache) fill(ctx context.Context, key, value string, ttl time.Duration, gen uint64) {
+	t.genMu.Lock()
+	defer t.genMu.Unlock()
+	if t.generationLocked(key) != gen {
+		return
+	}
+	t.local.SetWithTTL(ctx, key, value, ttl)
+}
+
+// invalidate drops keys locally and tells the other instances to do the same
+func (t *tieredCache) invalidate(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	t.drop(ctx, keys...)
+
+	_, err := t.remote.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, key := range keys {
+			p.Publish(ctx, t.channel, invalidateKey+key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.log.Errorf("Tiered cache failed to broadcast invalidation: %v", err)
+	}
+}
+
+func (t *tieredCache) invalidatePattern(ctx context.Context, pattern string) {
+	t.dropPattern(ctx, pattern)
+	if err := t.remote.client.Publish(ctx, t.channel, invalidatePattern+pattern).Err(); err != nil {
+		t.log.Errorf("Tiered cache failed to broadcast invalidation: %v", err)
+	}
+}
+
+// fetch reads keys missing locally from Redis with their ttl and keeps them
+// locally for at most LocalTTL, unless they were invalidated meanwhile
+func (t *tieredCache) fetch(ctx context.Context, keys []string) ([]KeyResult, error) {
+	gens := make([]uint64, len(keys))
+	for i, key := range keys {
+		gens[i] = t.generation(key)
+	}
+
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	err := t.remote.pipelined(ctx, false, func(p redis.Pipeliner) {
+		for i, key := range keys {
+			gets[i] = p.Get(ctx, key)
+			ttls[i] = p.PTTL(ctx, key)
+		}
+	})
+
+	results := make([]KeyResult, len(keys))
+	for i, key := range keys {
+		value, getErr := gets[i].Result()
+		results[i] = KeyResult{Key: key, Value: value, Err: getErr}
+		if errors.Is(getErr, redis.Nil) {
+			results[i].Err = ErrMiss
+			continue
+		}
+		if getErr != nil {
+			continue
+		}
+
+		ttl := t.localTTL
+		if remaining := ttls[i].Val(); remaining > 0 && remaining < ttl {
+			ttl = remaining
+		}
+		t.fill(ctx, key, value, ttl, gens[i])
+	}
+	return results, err
+}
+
+func (t *tieredCache) Set(key, value string, ttl int) CacheResult {
+	return t.SetContext(context.Background(), key, value, ttl)
+}
+
+func (t *tieredCache) Get(key string) CacheResult {
+	return t.GetContext(context.Background(), key)
+}
+
+func (t *tieredCache) Delete(key string) CacheResult {
+	return t.DeleteContext(context.Background(), key)
+}
+
+func (t *tieredCache) SetContext(ctx context.Context, key, value string, ttl int) CacheResult {
+	res := t.remote.SetContext(ctx, key, value, ttl)
+	t.invalidate(ctx, key)
+	return res
+}
+
+func (t *tieredCache) GetContext(ctx context.Context, key string) CacheResult {
+	if res := t.local.GetContext(ctx, key); !IsMiss(resultErr(res)) {
+		return res
+	}
+
+	results, err := t.fetch(ctx, []string{key})
+	if err != nil && len(results) == 0 {
+		return redis.NewStringResult("", err)
+	}
+	if IsMiss(results[0].Err) {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(results[0].Value, results[0].Err)
+}
+
+func resultErr(res CacheResult) error {
+	_, err := res.Result()
+	return err
+}
+
+func (t *tieredCache) DeleteContext(ctx context.Context, key string) CacheResult {
+	res := t.remote.DeleteContext(ctx, key)
+	t.invalidate(ctx, key)
+	return res
+}
+
+func (t *tieredCache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) CacheResult {
+	res := t.remote.SetWithTTL(ctx, key, value, ttl)
+	t.invalidate(ctx, key)
+	return res
+}
+
+func (t *tieredCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	ok, err := t.remote.SetNX(ctx, key, value, ttl)
+	if ok {
+		t.invalidate(ctx, key)
+	}
+	return ok, err
+}
+
+func (t *tieredCache) SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	ok, err := t.remote.SetXX(ctx, key, value, ttl)
+	if ok {
+		t.invalidate(ctx, key)
+	}
+	return ok, err
+}
+
+func (t *tieredCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := t.remote.Expire(ctx, key, ttl)
+	if ok {
+		t.invalidate(ctx, key)
+	}
+	return ok, err
+}
+
+func (t *tieredCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return t.remote.TTL(ctx, key)
+}
+
+func (t *tieredCache) Persist(ctx context.Context, key string) (bool, error) {
+	ok, err := t.remote.Persist(ctx, key)
+	if ok {
+		t.invalidate(ctx, key)
+	}
+	return ok, err
+}
+
+func (t *tieredCache) TryLock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	return t.remote.TryLock(ctx, key, ttl)
+}
+
+func (t *tieredCache) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	return t.remote.Lock(ctx, key, ttl)
+}
+
+func (t *tieredCache) Allow(ctx context.Context, key string, limit Limit) (*RateLimitResult, error) {
+	return t.remote.Allow(ctx, key, limit)
+}
+
+func (t *tieredCache) MGet(ctx context.Context, keys ...string) ([]KeyResult, error) {
+	results, _ := t.local.MGet(ctx, keys...)
+
+	var (
+		missing []string
+		index   []int
+	)
+	for i, res := range results {
+		if res.Err != nil {
+			missing = append(missing, res.Key)
+			index = append(index, i)
+		}
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	fetched, err := t.fetch(ctx, missing)
+	for i, res := range fetched {
+		results[index[i]] = res
+	}
+	return results, err
+}
+
+func (t *tieredCache) MSet(ctx context.Context, values map[string]string, ttl time.Duration) ([]KeyResult, error) {
+	results, err := t.remote.MSet(ctx, values, ttl)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	t.invalidate(ctx, keys...)
+	return results, err
+}
+
+func (t *tieredCache) DeleteMany(ctx context.Context, keys ...string) ([]KeyResult, error) {
+	results, err := t.remote.DeleteMany(ctx, keys...)
+	t.invalidate(ctx, keys...)
+	return results, err
+}
+
+func (t *tieredCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	n, err := t.remote.DeleteByPattern(ctx, pattern)
+	t.invalidatePattern(ctx, pattern)
+	return n, err
+}
+
+func (t *tieredCache) Pipeline(ctx context.Context, fn func(Pipe) error) error {
+	return t.pipe(ctx, fn, t.remote.Pipeline)
+}
+
+func (t *tieredCache) TxPipeline(ctx context.Context, fn func(Pipe) error) error {
+	return t.pipe(ctx, fn, t.remote.TxPipeline)
+}
+
+// pipe runs the pipeline on Redis and invalidates the keys it wrote
+func (t *tieredCache) pipe(ctx context.Context, fn func(Pipe) error, run func(context.Context, func(Pipe) error) error) error {
+	var written []string
+	err := run(ctx, func(p Pipe) error {
+		return fn(&recordingPipe{Pipe: p, written: &written})
+	})
+	t.invalidate(ctx, written...)
+	return err
+}
+
+// recordingPipe remembers the keys written through it
+type recordingPipe struct {
+	Pipe
+	written *[]string
+}
+
+func (p *recordingPipe) Set(key, value string, ttl time.Duration) CacheResult {
+	*p.written = append(*p.written, key)
+	return p.Pipe.Set(key, value, ttl)
+}
+
+func (p *recordingPipe) Delete(key string) CacheResult {
+	*p.written = append(*p.written, key)
+	return p.Pipe.Delete(key)
+}
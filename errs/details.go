@@ -0,0 +1,254 @@
+package errs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"github.com/pkg/errors"
+)
+
+// errorInfo is what an error tells a client: a machine-readable code, a
+// message safe to show and details such as the field or resource id. It is
+// kept behind a pointer so customError stays comparable.
+type errorInfo struct {
+	code        string
+	safeMessage string
+	details     map[string]interface{}
+}
+
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// Code is the machine-readable code of the type, used when an error has none
+func (et ErrorType) Code() string {
+	switch et {
+	case Invalidated:
+		return "invalidated"
+	case Unauthorized:
+		return "unauthorized"
+	case Forbidden:
+		return "forbidden"
+	case NotFound:
+		return "not_found"
+	case Conflict:
+		return "conflict"
+	case Failed:
+		return "failed"
+	case TooManyRequests:
+		return "too_many_requests"
+	case Timeout:
+		return "timeout"
+	case Unavailable:
+		return "unavailable"
+	case PreconditionFailed:
+		return "precondition_failed"
+	case Unprocessable:
+		return "unprocessable"
+	case NotImplemented:
+		return "not_implemented"
+	default:
+		return "unknown"
+	}
+}
+
+// withInfo returns a copy of err whose info has been changed by fn. An error
+// that is not from this package is wrapped, keeping its type.
+func withInfo(err error, fn func(*errorInfo)) error {
+	if err == nil {
+		return nil
+	}
+	ce, ok := err.(customError)
+	if !ok {
+		ce = customError{errorType: GetType(err), originalError: err}
+	}
+
+	info := &errorInfo{}
+	if ce.info != nil {
+		*info = *ce.info
+		info.details = make(map[string]interface{}, len(ce.info.details))
+		for k, v := range ce.info.details {
+			info.details[k] = v
+		}
+	}
+	fn(info)
+	ce.info = info
+	return ce
+}
+
+// WithCode sets the machine-readable code of err, e.g. "user_not_found"
+func WithCode(err error, code string) error {
+	return withInfo(err, func(info *errorInfo) { info.code = code })
+}
+
+// WithSafeMessage sets the message of err shown to clients, Error() keeps
+// the internal one
+func WithSafeMessage(err error, message string) error {
+	return withInfo(err, func(info *errorInfo) { info.safeMessage = message })
+}
+
+// WithDetail adds a key/value detail to err, e.g. the invalid field
+func WithDetail(err error, key string, value interface{}) error {
+	return withInfo(err, func(info *errorInfo) {
+		if info.details == nil {
+			info.details = make(map[string]interface{})
+		}
+		info.details[key] = value
+	})
+}
+
+// WithDetails adds key/value details to err
+func WithDetails(err error, details map[string]interface{}) error {
+	return withInfo(err, func(info *errorInfo) {
+		if info.details == nil {
+			info.details = make(map[string]interface{}, len(details))
+		}
+		for k, v := range details {
+			info.details[k] = v
+		}
+	})
+}
+
+// chain calls fn for every error in the chain of err, outermost first, until
+// it returns false
+func chain(err error, fn func(error) bool) {
+	for err != nil && fn(err) {
+		if next := errors.Unwrap(err); next != nil {
+			err = next
+			continue
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return
+		}
+		err = c.Cause()
+	}
+}
+
+// GetCode returns the outermost code in the chain of err, or the code of its
+// type when none was set
+func GetCode(err error) string {
+	code := ""
+	chain(err, func(e error) bool {
+		if ce, ok := e.(customError); ok && ce.info != nil && ce.info.code != "" {
+			code = ce.info.code
+			return false
+		}
+		return true
+	})
+	if code == "" {
+		return GetType(err).Code()
+	}
+	return code
+}
+
+// GetSafeMessage returns the outermost safe message in the chain of err, or
+// the status text of its http code, never the internal message
+func GetSafeMessage(err error) string {
+	message := ""
+	chain(err, func(e error) bool {
+		if ce, ok := e.(customError); ok && ce.info != nil && ce.info.safeMessage != "" {
+			message = ce.info.safeMessage
+			return false
+		}
+		return true
+	})
+	if message == "" {
+		return http.StatusText(GetHttpCode(err))
+	}
+	return message
+}
+
+// GetDetails merges the details of the chain of err, the outer ones winning
+func GetDetails(err error) map[string]interface{} {
+	var details map[string]interface{}
+	chain(err, func(e error) bool {
+		ce, ok := e.(customError)
+		if !ok || ce.info == nil {
+			return true
+		}
+		for k, v := range ce.info.details {
+			if details == nil {
+				details = make(map[string]interface{})
+			}
+			if _, set := details[k]; !set {
+				details[k] = v
+			}
+		}
+		return true
+	})
+	return details
+}
+
+// GetStackTrace returns the innermost stack captured by pkg/errors, which is
+// the closest to where the error happened
+func GetStackTrace(err error) errors.StackTrace {
+	var stack errors.StackTrace
+	chain(err, func(e error) bool {
+		if st, ok := e.(stackTracer); ok {
+			stack = st.StackTrace()
+		}
+		return true
+	})
+	return stack
+}
+
+// Format prints the internal message for %s and %v, %+v adds the type, code,
+// details and the stack
+func (e customError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%s [type=%s code=%s", e.Error(), e.errorType, GetCode(e))
+			if details := GetDetails(e); len(details) > 0 {
+				fmt.Fprintf(s, " details=%s", formatDetails(details))
+			}
+			io.WriteString(s, "]")
+			if stack := GetStackTrace(e); len(stack) > 0 {
+				fmt.Fprintf(s, "%+v", stack)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+func formatDetails(details map[string]interface{}) string {
+	keys := make([]string, 0, len(details))
+	for k := range details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", k, details[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// LogError logs the full chain of err with its code, details and stack. Use
+// it server side, clients should only get GetCode, GetSafeMessage and
+// GetDetails.
+func LogError(log logger.Logger, err error) {
+	if err == nil {
+		return
+	}
+	if _, ok := err.(customError); !ok {
+		err = customError{errorType: GetType(err), originalError: err}
+	}
+	if GetHttpCode(err) >= http.StatusInternalServerError {
+		log.Errorf("%+v", err)
+		return
+	}
+	log.Warnf("%+v", err)
+}
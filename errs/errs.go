@@ -16,8 +16,58 @@ const (
 	NotFound
 	Conflict
 	Failed
+	TooManyRequests
+	Timeout
+	Unavailable
+	PreconditionFailed
+	Unprocessable
+	NotImplemented
 )
 
+// String names the type, e.g. "NotFound"
+func (et ErrorType) String() string {
+	switch et {
+	case Invalidated:
+		return "Invalidated"
+	case Unauthorized:
+		return "Unauthorized"
+	case Forbidden:
+		return "Forbidden"
+	case NotFound:
+		return "NotFound"
+	case Conflict:
+		return "Conflict"
+	case Failed:
+		return "Failed"
+	case TooManyRequests:
+		return "TooManyRequests"
+	case Timeout:
+		return "Timeout"
+	case Unavailable:
+		return "Unavailable"
+	case PreconditionFailed:
+		return "PreconditionFailed"
+	case Unprocessable:
+		return "Unprocessable"
+	case NotImplemented:
+		return "NotImplemented"
+	default:
+		return "Unknown"
+	}
+}
+
+// Error makes an ErrorType usable as the target of errors.Is, which matches
+// any error of that type in the chain, e.g. errors.Is(err, errs.NotFound)
+func (et ErrorType) Error() string {
+	return et.String()
+}
+
+// Type lets a bare ErrorType returned as an error, e.g. return errs.NotFound,
+// have its own type
+func (et ErrorType) Type() ErrorType {
+	return et
+}
+
 type typeGetter interface {
 	Type() ErrorType
 }
@@ -25,6 +75,7 @@ type typeGetter interface {
 type customError struct {
 	errorType     ErrorType
 	originalError error
+	info          *errorInfo
 }
 
 func (et ErrorType) New(message string) error {
@@ -51,32 +102,62 @@ func (e customError) Type() ErrorType {
 	return e.errorType
 }
 
-func Wrap(err error, message string) error {
-	we := errors.Wrap(err, message)
-	if ce, ok := err.(typeGetter); ok {
-		return customError{errorType: ce.Type(), originalError: we}
+func (e customError) Unwrap() error {
+	return e.originalError
+}
+
+// Cause lets Cause reach the root error through a customError
+func (e customError) Cause() error {
+	return e.originalError
+}
+
+// Is reports whether target is the ErrorType of e
+func (e customError) Is(target error) bool {
+	et, ok := target.(ErrorType)
+	return ok && et == e.errorType
+}
+
+// As sets an *ErrorType target to the type of e
+func (e customError) As(target interface{}) bool {
+	et, ok := target.(*ErrorType)
+	if ok {
+		*et = e.errorType
 	}
-	return customError{errorType: Unknown, originalError: we}
+	return ok
+}
+
+func Wrap(err error, message string) error {
+	return customError{errorType: GetType(err), originalError: errors.Wrap(err, message)}
 }
 
 func Cause(err error) error {
 	return errors.Cause(err)
 }
 
+// GetType returns the type of the outermost customError in the chain of e,
+// following Unwrap and Cause, or Unknown when there is none
 func GetType(e error) ErrorType {
-	for {
+	for e != nil {
 		if tg, ok := e.(typeGetter); ok {
 			return tg.Type()
 		}
-		break
+		if next := errors.Unwrap(e); next != nil {
+			e = next
+			continue
+		}
+		c, ok := e.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		e = c.Cause()
 	}
 	return Unknown
 }
 
+// GetHttpCode maps the type of e to a status code. Unknown errors are server
+// errors, a client error has to be typed as such.
 func GetHttpCode(e error) int {
 	switch GetType(e) {
-	case Unknown:
-		return http.StatusBadRequest
 	case Invalidated:
 		return http.StatusBadRequest
 	case Unauthorized:
@@ -87,9 +168,19 @@ func GetHttpCode(e error) int {
 		return http.StatusNotFound
 	case Conflict:
 		return http.StatusConflict
-	case Failed:
-		return http.StatusInternalServerError
+	case TooManyRequests:
+		return http.StatusTooManyRequests
+	case Timeout:
+		return http.StatusGatewayTimeout
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case PreconditionFailed:
+		return http.StatusPreconditionFailed
+	case Unprocessable:
+		return http.StatusUnprocessableEntity
+	case NotImplemented:
+		return http.StatusNotImplemented
 	default:
-		return http.StatusBadRequest
+		return http.StatusInternalServerError
 	}
 }
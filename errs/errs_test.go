@@ -0,0 +1,64 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestGetType(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		want     ErrorType
+		wantCode int
+	}{
+		{"nil", nil, Unknown, http.StatusInternalServerError},
+		{"plain error", errors.New("boom"), Unknown, http.StatusInternalServerError},
+		{"typed error", NotFound.New("user not found"), NotFound, http.StatusNotFound},
+		{"bare type", NotFound, NotFound, http.StatusNotFound},
+		{"wrapped bare type", fmt.Errorf("load user: %w", Conflict), Conflict, http.StatusConflict},
+		{"wrapped with fmt", fmt.Errorf("load user: %w", Forbidden.New("no access")), Forbidden, http.StatusForbidden},
+		{"wrapped with pkg/errors", pkgerrors.Wrap(Invalidated.New("bad id"), "parse"), Invalidated, http.StatusBadRequest},
+		{"rewrapped keeps the type", Wrap(Timeout.New("slow"), "call"), Timeout, http.StatusGatewayTimeout},
+		{"outermost type wins", Failed.Wrap(NotFound.New("row"), "query"), Failed, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetType(tt.err); got != tt.want {
+				t.Errorf("GetType() = %s, want %s", got, tt.want)
+			}
+			if got := GetHttpCode(tt.err); got != tt.wantCode {
+				t.Errorf("GetHttpCode() = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestIs(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"typed error", NotFound.New("user"), NotFound, true},
+		{"other type", NotFound.New("user"), Conflict, false},
+		{"bare type", NotFound, NotFound, true},
+		{"wrapped", fmt.Errorf("load: %w", NotFound.New("user")), NotFound, true},
+		{"inner type", Failed.Wrap(NotFound.New("row"), "query"), NotFound, true},
+		{"plain error", errors.New("boom"), Unknown, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%v, %s) = %t, want %t", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
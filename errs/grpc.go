@@ -0,0 +1,127 @@
+package errs
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCCode is the gRPC status code of the type
+func (et ErrorType) GRPCCode() codes.Code {
+	switch et {
+	case Invalidated, Unprocessable:
+		return codes.InvalidArgument
+	case Unauthorized:
+		return codes.Unauthenticated
+	case Forbidden:
+		return codes.PermissionDenied
+	case NotFound:
+		return codes.NotFound
+	case Conflict:
+		return codes.Aborted
+	case Failed:
+		return codes.Internal
+	case TooManyRequests:
+		return codes.ResourceExhausted
+	case Timeout:
+		return codes.DeadlineExceeded
+	case Unavailable:
+		return codes.Unavailable
+	case PreconditionFailed:
+		return codes.FailedPrecondition
+	case NotImplemented:
+		return codes.Unimplemented
+	default:
+		return codes.Unknown
+	}
+}
+
+// GetGRPCCode maps the type of e to a gRPC status code, codes.OK for nil
+func GetGRPCCode(e error) codes.Code {
+	if e == nil {
+		return codes.OK
+	}
+	return GetType(e).GRPCCode()
+}
+
+// TypeFromGRPCCode maps a gRPC status code back to an ErrorType
+func TypeFromGRPCCode(code codes.Code) ErrorType {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return Invalidated
+	case codes.Unauthenticated:
+		return Unauthorized
+	case codes.PermissionDenied:
+		return Forbidden
+	case codes.NotFound:
+		return NotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return Conflict
+	case codes.Internal, codes.DataLoss:
+		return Failed
+	case codes.ResourceExhausted:
+		return TooManyRequests
+	case codes.DeadlineExceeded:
+		return Timeout
+	case codes.Unavailable:
+		return Unavailable
+	case codes.FailedPrecondition:
+		return PreconditionFailed
+	case codes.Unimplemented:
+		return NotImplemented
+	default:
+		return Unknown
+	}
+}
+
+// GRPCStatus lets grpc-go send the error with the code of its type and its
+// safe message
+func (e customError) GRPCStatus() *status.Status {
+	return ToGRPCStatus(e)
+}
+
+// ToGRPCStatus converts e to a gRPC status with the code of its type and its
+// safe message. An untyped error keeps the status of a failed gRPC call in
+// its chain, if any.
+func ToGRPCStatus(e error) *status.Status {
+	if e == nil {
+		return nil
+	}
+	if GetType(e) == Unknown {
+		var st *status.Status
+		chain(e, func(err error) bool {
+			if _, own := err.(customError); own {
+				return true
+			}
+			if gs, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+				st = gs.GRPCStatus()
+				return false
+			}
+			return true
+		})
+		if st != nil {
+			return st
+		}
+	}
+	return status.New(GetGRPCCode(e), GetSafeMessage(e))
+}
+
+// FromGRPCCode builds the error of a gRPC status received from another
+// service, nil for codes.OK
+func FromGRPCCode(code codes.Code, message string) error {
+	if code == codes.OK {
+		return nil
+	}
+	return TypeFromGRPCCode(code).New(message)
+}
+
+// FromGRPCError types an error returned by a gRPC call from its status code,
+// keeping the status message as safe message. Other errors are returned as
+// they are.
+func FromGRPCError(e error) error {
+	st, ok := status.FromError(e)
+	if !ok || st.Code() == codes.OK {
+		return e
+	}
+	return WithSafeMessage(customError{errorType: TypeFromGRPCCode(st.Code()), originalError: errors.WithStack(e)}, st.Message())
+}
@@ -0,0 +1,52 @@
+// Package grpcerrs turns errs errors into gRPC statuses at the edges of a
+// service. It is kept apart from errs so that only gRPC services depend on
+// grpc-go.
+package grpcerrs
+
+import (
+	"context"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// UnaryServerInterceptor returns errors of the handlers as gRPC statuses with
+// the code of their type and only their safe message. Server errors are
+// logged with their chain and stack.
+func UnaryServerInterceptor(log logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, serverError(log, info.FullMethod, err)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls
+func StreamServerInterceptor(log logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return serverError(log, info.FullMethod, handler(srv, ss))
+	}
+}
+
+// UnaryClientInterceptor types the errors of unary calls with errs.FromGRPCError
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return errs.FromGRPCError(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+func serverError(log logger.Logger, method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	st := errs.ToGRPCStatus(err)
+	switch st.Code() {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded, codes.Unimplemented:
+		errs.LogError(log, err)
+	default:
+		log.Debugf("%s failed with %s: %v", method, st.Code(), err)
+	}
+	return st.Err()
+}
@@ -4,7 +4,16 @@ type SqlHandler interface {
 	Exec(string, ...interface{}) (Result, error)
 	Query(string, ...interface{}) (Row, error)
 	Transaction(func() (interface{}, error)) (interface{}, error)
+	TransactionWithTx(func(Tx) (interface{}, error)) (interface{}, error)
 	MultiExec(string) error
+	MultiExecWithResults(string) ([]Result, error)
+	Close() error
+}
+
+// Tx runs statements inside the transaction opened by TransactionWithTx
+type Tx interface {
+	Exec(string, ...interface{}) (Result, error)
+	Query(string, ...interface{}) (Row, error)
 }
 
 type Result interface {
@@ -0,0 +1,211 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statement is a single SQL statement taken from a multi statement script
+type Statement struct {
+	Query string
+	Line  int
+}
+
+// StatementError reports which statement of a script failed and where it starts
+type StatementError struct {
+	Index     int
+	Line      int
+	Statement string
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d at line %d failed: %v", e.Index+1, e.Line, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// Dialect selects the SQL syntax SplitStatements understands
+type Dialect int
+
+const (
+	// MySQL has backslash escapes in quoted strings, backtick identifiers and
+	// # comments, -- only starts a comment when followed by whitespace
+	MySQL Dialect = iota
+	// Postgres has standard conforming strings, where a backslash is only an
+	// escape in E'...' strings, dollar-quoted bodies and nested block comments
+	Postgres
+)
+
+// SplitStatements splits a script on semicolons, ignoring the ones inside
+// quotes, comments and PostgreSQL dollar-quoted bodies. Empty statements are dropped.
+func SplitStatements(script string, dialect Dialect) []Statement {
+	var (
+		statements []Statement
+		current    strings.Builder
+		line       = 1
+		startLine  = 0
+		dollarTag  string
+	)
+
+	flush := func() {
+		query := strings.TrimSpace(current.String())
+		if query != "" && startLine > 0 {
+			statements = append(statements, Statement{Query: query, Line: startLine})
+		}
+		current.Reset()
+		startLine = 0
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+
+		switch {
+		case dollarTag != "":
+			if strings.HasPrefix(script[i:], dollarTag) {
+				current.WriteString(dollarTag)
+				i += len(dollarTag) - 1
+				dollarTag = ""
+				continue
+			}
+		case lineComment(script, i, dialect):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			current.WriteString(script[i : i+end])
+			i += end - 1
+			continue
+		case c == '/' && i+1 < len(script) && script[i+1] == '*':
+			end := blockCommentEnd(script, i, dialect == Postgres)
+			comment := script[i:end]
+			current.WriteString(comment)
+			line += strings.Count(comment, "\n")
+			i = end - 1
+			continue
+		case c == '\'' || c == '"' || c == '`' && dialect == MySQL:
+			if startLine == 0 {
+				startLine = line
+			}
+			escapes := dialect == MySQL && c != '`' || c == '\'' && escapeString(script, i)
+			end := closingQuote(script, i, escapes)
+			quoted := script[i:end]
+			current.WriteString(quoted)
+			line += strings.Count(quoted, "\n")
+			i = end - 1
+			continue
+		case c == '$' && dialect == Postgres && (i == 0 || !identChar(script[i-1])):
+			if tag := dollarQuoteTag(script[i:]); tag != "" {
+				if startLine == 0 {
+					startLine = line
+				}
+				dollarTag = tag
+				current.WriteString(tag)
+				i += len(tag) - 1
+				continue
+			}
+		case c == ';':
+			flush()
+			continue
+		}
+
+		if c == '\n' {
+			line++
+		} else if startLine == 0 && c != ' ' && c != '\t' && c != '\r' {
+			startLine = line
+		}
+		current.WriteByte(c)
+	}
+	flush()
+
+	return statements
+}
+
+// lineComment reports whether a comment running to the end of the line
+// starts at i
+func lineComment(script string, i int, dialect Dialect) bool {
+	if dialect == MySQL && script[i] == '#' {
+		return true
+	}
+	if script[i] != '-' || i+1 >= len(script) || script[i+1] != '-' {
+		return false
+	}
+	if dialect == MySQL && i+2 < len(script) {
+		switch script[i+2] {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// blockCommentEnd returns the index just past the comment starting at start,
+// counting nested comments when nested is set
+func blockCommentEnd(script string, start int, nested bool) int {
+	depth := 0
+	for i := start; i+1 < len(script); i++ {
+		switch {
+		case script[i] == '/' && script[i+1] == '*' && (nested || depth == 0):
+			depth++
+			i++
+		case script[i] == '*' && script[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(script)
+}
+
+// escapeString reports whether the quote at i opens a PostgreSQL E'...' string
+func escapeString(script string, i int) bool {
+	if i == 0 || script[i-1] != 'E' && script[i-1] != 'e' {
+		return false
+	}
+	return i == 1 || !identChar(script[i-2])
+}
+
+func identChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// closingQuote returns the index just past the quote that closes the one at
+// start. A doubled quote is part of the string, a backslash only escapes the
+// next character when escapes is set.
+func closingQuote(script string, start int, escapes bool) int {
+	quote := script[start]
+	for i := start + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(script)
+}
+
+// dollarQuoteTag returns the opening tag ($$ or $name$) at the start of s, if any
+func dollarQuoteTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package gateway
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		script  string
+		want    []Statement
+	}{
+		{
+			name:    "plain statements",
+			dialect: MySQL,
+			script:  "CREATE TABLE a (id INT);\nINSERT INTO a VALUES (1);",
+			want: []Statement{
+				{Query: "CREATE TABLE a (id INT)", Line: 1},
+				{Query: "INSERT INTO a VALUES (1)", Line: 2},
+			},
+		},
+		{
+			name:    "empty statements are dropped",
+			dialect: Postgres,
+			script:  ";;\n  ;\nSELECT 1;\n",
+			want:    []Statement{{Query: "SELECT 1", Line: 3}},
+		},
+		{
+			name:    "semicolons in quotes",
+			dialect: MySQL,
+			script:  "INSERT INTO a VALUES ('x;y', \"z;\");\nSELECT `a;b` FROM a;",
+			want: []Statement{
+				{Query: "INSERT INTO a VALUES ('x;y', \"z;\")", Line: 1},
+				{Query: "SELECT `a;b` FROM a", Line: 2},
+			},
+		},
+		{
+			name:    "doubled quotes",
+			dialect: Postgres,
+			script:  "SELECT 'it''s;';\nSELECT 2;",
+			want: []Statement{
+				{Query: "SELECT 'it''s;'", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name:    "mysql backslash escapes",
+			dialect: MySQL,
+			script:  "SELECT 'a\\';b';\nSELECT 2;",
+			want: []Statement{
+				{Query: "SELECT 'a\\';b'", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name:    "postgres standard conforming strings",
+			dialect: Postgres,
+			script:  "INSERT INTO paths VALUES ('C:\\');\nSELECT 2;",
+			want: []Statement{
+				{Query: "INSERT INTO paths VALUES ('C:\\')", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name:    "postgres escape strings",
+			dialect: Postgres,
+			script:  "SELECT E'a\\';b';\nSELECT 2;",
+			want: []Statement{
+				{Query: "SELECT E'a\\';b'", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name:    "line comments",
+			dialect: Postgres,
+			script:  "-- setup; of a\nSELECT 1; -- trailing;\nSELECT 2;",
+			want: []Statement{
+				{Query: "-- setup; of a\nSELECT 1", Line: 2},
+				{Query: "-- trailing;\nSELECT 2", Line: 3},
+			},
+		},
+		{
+			name:    "mysql hash comments",
+			dialect: MySQL,
+			script:  "# drop; it\nSELECT 1;\nSELECT 2 # done;\n;",
+			want: []Statement{
+				{Query: "# drop; it\nSELECT 1", Line: 2},
+				{Query: "SELECT 2 # done;", Line: 3},
+			},
+		},
+		{
+			name:    "mysql double dash needs whitespace",
+			dialect: MySQL,
+			script:  "SELECT 1--1;\nSELECT 2;",
+			want: []Statement{
+				{Query: "SELECT 1--1", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name:    "postgres has no hash comments",
+			dialect: Postgres,
+			script:  "SELECT '{}'::jsonb #> '{a}';\nSELECT 2;",
+			want: []Statement{
+				{Query: "SELECT '{}'::jsonb #> '{a}'", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name:    "block comments",
+			dialect: MySQL,
+			script:  "/* first;\nsecond; */\nSELECT 1;\nSELECT 2;",
+			want: []Statement{
+				{Query: "/* first;\nsecond; */\nSELECT 1", Line: 3},
+				{Query: "SELECT 2", Line: 4},
+			},
+		},
+		{
+			name:    "postgres nested block comments",
+			dialect: Postgres,
+			script:  "/* a /* b; */ c; */ SELECT 1;\nSELECT 2;",
+			want: []Statement{
+				{Query: "/* a /* b; */ c; */ SELECT 1", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name:    "dollar quoted bodies",
+			dialect: Postgres,
+			script:  "CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql;\nSELECT $1;",
+			want: []Statement{
+				{Query: "CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql", Line: 1},
+				{Query: "SELECT $1", Line: 6},
+			},
+		},
+		{
+			name:    "unterminated quote",
+			dialect: Postgres,
+			script:  "SELECT 1;\nSELECT 'open;",
+			want: []Statement{
+				{Query: "SELECT 1", Line: 1},
+				{Query: "SELECT 'open;", Line: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitStatements(tt.script, tt.dialect)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
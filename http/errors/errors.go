@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
 )
 
 const (
@@ -19,6 +21,7 @@ const (
 	ErrUnauthorized       = "Unauthorized"
 	ErrForbidden          = "Forbidden"
 	ErrBadQueryParams     = "Invalid query params"
+	ErrTooManyRequests    = "Too Many Requests"
 )
 
 var (
@@ -40,6 +43,9 @@ var (
 	InvalidJWTClaims      = errors.New("Invalid JWT claims")
 	NotAllowedImageHeader = errors.New("Not allowed image header")
 	NoCookie              = errors.New("not found cookie header")
+	TooManyRequests       = errors.New("Too Many Requests")
+	RequestInProgress     = errors.New("Request with the same idempotency key in progress")
+	IdempotencyKeyReused  = errors.New("Idempotency key reused with a different request")
 )
 
 // Rest error interface
@@ -51,9 +57,11 @@ type RestErr interface {
 
 // Rest error struct
 type RestError struct {
-	ErrStatus int         `json:"status,omitempty"`
-	ErrError  string      `json:"error,omitempty"`
-	ErrCauses interface{} `json:"-"`
+	ErrStatus  int                    `json:"status,omitempty"`
+	ErrError   string                 `json:"error,omitempty"`
+	ErrCode    string                 `json:"code,omitempty"`
+	ErrDetails map[string]interface{} `json:"details,omitempty"`
+	ErrCauses  interface{}            `json:"-"`
 }
 
 // Error  Error() interface method
@@ -144,15 +152,43 @@ func NewInternalServerError(causes interface{}) RestErr {
 	return result
 }
 
-// Parser of error string messages returns RestError
+// New Too Many Requests Error
+func NewTooManyRequestsError(causes interface{}) RestErr {
+	return RestError{
+		ErrStatus: http.StatusTooManyRequests,
+		ErrError:  TooManyRequests.Error(),
+		ErrCauses: causes,
+	}
+}
+
+// New Rest Error from an errs error, with its safe message, code and details
+// so the internal message stays out of the response
+func NewRestErrorFromErrs(err error) RestErr {
+	return RestError{
+		ErrStatus:  errs.GetHttpCode(err),
+		ErrError:   errs.GetSafeMessage(err),
+		ErrCode:    errs.GetCode(err),
+		ErrDetails: errs.GetDetails(err),
+		ErrCauses:  err,
+	}
+}
+
+// Parser of error string messages returns RestError. A RestErr anywhere in
+// the chain wins, then the database and deadline errors, which the sql
+// handlers wrap in errs.Failed, then the errs types other than Failed.
 func ParseErrors(err error) RestErr {
+	var restErr RestErr
 	switch {
+	case errors.As(err, &restErr):
+		return restErr
 	case errors.Is(err, sql.ErrNoRows):
 		return NewRestError(http.StatusNotFound, NotFound.Error(), err)
 	case errors.Is(err, context.DeadlineExceeded):
 		return NewRestError(http.StatusRequestTimeout, RequestTimeoutError.Error(), err)
 	case strings.Contains(err.Error(), "SQLSTATE"):
 		return parseSqlErrors(err)
+	case errs.GetType(err) != errs.Unknown && errs.GetType(err) != errs.Failed:
+		return NewRestErrorFromErrs(err)
 	case strings.Contains(err.Error(), "Field validation"):
 		return parseValidatorError(err)
 	case strings.Contains(err.Error(), "Unmarshal"):
@@ -165,10 +201,9 @@ func ParseErrors(err error) RestErr {
 		return NewRestError(http.StatusUnauthorized, Unauthorized.Error(), err)
 	case strings.Contains(strings.ToLower(err.Error()), "bcrypt"):
 		return NewRestError(http.StatusBadRequest, BadRequest.Error(), err)
+	case errs.GetType(err) == errs.Failed:
+		return NewRestErrorFromErrs(err)
 	default:
-		if restErr, ok := err.(RestErr); ok {
-			return restErr
-		}
 		return NewInternalServerError(err)
 	}
 }
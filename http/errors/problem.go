@@ -0,0 +1,299 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	ContentTypeProblemJSON = "application/problem+json"
+	ContentTypeJSON        = "application/json"
+
+	// ProblemTypeBlank is the problem type when the status says it all
+	ProblemTypeBlank = "about:blank"
+
+	maxProblemBodySize = 1 << 20
+)
+
+// ProblemTypeBase, when set, turns the code of an errs error into the problem
+// type URI, e.g. "https://example.com/problems/" + "not_found"
+var ProblemTypeBase = ""
+
+// InvalidParam is one failed validation, the "invalid-params" extension
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Problem is an RFC 7807 problem details object. Extensions are written as
+// top level members next to the standard ones.
+type Problem struct {
+	Type       string
+	Title      string
+	StatusCode int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+var problemMembers = map[string]bool{"type": true, "title": true, "status": true, "detail": true, "instance": true}
+
+// New Problem for status with the status text as title
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:       ProblemTypeBlank,
+		Title:      http.StatusText(status),
+		StatusCode: status,
+		Detail:     detail,
+	}
+}
+
+// New Problem from any error, parsed by ParseErrors. Only the safe message of
+// an errs error is used as detail, its code and details become extensions and
+// validation errors are listed in "invalid-params".
+func NewProblemFromError(err error, instance string) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		p := *problem
+		p.Extensions = make(map[string]interface{}, len(problem.Extensions))
+		for k, v := range problem.Extensions {
+			p.Extensions[k] = v
+		}
+		return &p
+	}
+
+	// Error() of a RestErr carries its causes, which are internal, so only
+	// the message of a RestError makes it into the detail
+	restErr := ParseErrors(err)
+	p := NewProblem(restErr.Status(), http.StatusText(restErr.Status()))
+	p.Instance = instance
+
+	var re *RestError
+	switch v := restErr.(type) {
+	case RestError:
+		re = &v
+	case *RestError:
+		re = v
+	}
+	if re != nil {
+		if re.ErrError != "" {
+			p.Detail = re.ErrError
+		}
+		if re.ErrCode != "" {
+			p.WithExtension("code", re.ErrCode)
+			if ProblemTypeBase != "" {
+				p.Type = ProblemTypeBase + re.ErrCode
+			}
+		}
+		for k, v := range re.ErrDetails {
+			p.WithExtension(k, v)
+		}
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		params := make([]InvalidParam, len(validationErrors))
+		for i, fe := range validationErrors {
+			params[i] = InvalidParam{Name: fe.Field(), Reason: fmt.Sprintf("failed on the '%s' tag", fe.Tag())}
+		}
+		p.WithExtension("invalid-params", params)
+	}
+
+	return p
+}
+
+// WithExtension sets an extension member, the standard members can not be
+// overwritten
+func (p *Problem) WithExtension(key string, value interface{}) *Problem {
+	if problemMembers[key] {
+		return p
+	}
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// WithTraceID sets the "trace_id" extension, skipped when id is empty
+func (p *Problem) WithTraceID(id string) *Problem {
+	if id == "" {
+		return p
+	}
+	return p.WithExtension("trace_id", id)
+}
+
+// Error  Error() interface method
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("status: %d - %s", p.StatusCode, p.Title)
+	}
+	return fmt.Sprintf("status: %d - %s: %s", p.StatusCode, p.Title, p.Detail)
+}
+
+// Problem status
+func (p *Problem) Status() int {
+	return p.StatusCode
+}
+
+// Problem Causes are its extension members
+func (p *Problem) Causes() interface{} {
+	return p.Extensions
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		if !problemMembers[k] {
+			members[k] = v
+		}
+	}
+
+	members["type"] = p.Type
+	if p.Type == "" {
+		members["type"] = ProblemTypeBlank
+	}
+	if p.Title != "" {
+		members["title"] = p.Title
+	}
+	if p.StatusCode != 0 {
+		members["status"] = p.StatusCode
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+	fields := map[string]interface{}{
+		"type":     &p.Type,
+		"title":    &p.Title,
+		"status":   &p.StatusCode,
+		"detail":   &p.Detail,
+		"instance": &p.Instance,
+	}
+	for k, raw := range members {
+		if field, ok := fields[k]; ok {
+			// a member of the wrong type is ignored, as RFC 7807 asks
+			json.Unmarshal(raw, field)
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]interface{})
+		}
+		p.Extensions[k] = v
+	}
+	if p.Type == "" {
+		p.Type = ProblemTypeBlank
+	}
+	return nil
+}
+
+// New Problem From Bytes
+func NewProblemFromBytes(bytes []byte) (*Problem, error) {
+	var p Problem
+	if err := json.Unmarshal(bytes, &p); err != nil {
+		return nil, errors.New("invalid json")
+	}
+	return &p, nil
+}
+
+// ParseProblemResponse reads the problem of an error response of another
+// service, nil for a successful one. A plain JSON RestError body or any
+// other body is turned into a problem with the response status.
+func ParseProblemResponse(resp *http.Response) (*Problem, error) {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProblemBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case ContentTypeProblemJSON:
+		p, err := NewProblemFromBytes(body)
+		if err != nil {
+			return nil, err
+		}
+		if p.StatusCode == 0 {
+			p.StatusCode = resp.StatusCode
+		}
+		return p, nil
+	case ContentTypeJSON:
+		var restErr RestError
+		if err := json.Unmarshal(body, &restErr); err == nil && restErr.ErrError != "" {
+			p := NewProblem(resp.StatusCode, restErr.ErrError)
+			if restErr.ErrCode != "" {
+				p.WithExtension("code", restErr.ErrCode)
+			}
+			for k, v := range restErr.ErrDetails {
+				p.WithExtension(k, v)
+			}
+			return p, nil
+		}
+	}
+
+	return NewProblem(resp.StatusCode, strings.TrimSpace(string(body))), nil
+}
+
+// AcceptsProblem reports whether an Accept header prefers problem+json over
+// plain JSON. Wildcards do not count, so clients that did not ask keep
+// getting RestError bodies.
+func AcceptsProblem(accept string) bool {
+	problemQ, jsonQ := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case ContentTypeProblemJSON:
+			problemQ = q
+		case ContentTypeJSON:
+			jsonQ = q
+		}
+	}
+	return problemQ > 0 && problemQ >= jsonQ
+}
+
+// ErrorResponseFor negotiates the error response from the Accept header,
+// returning its content type, status and body
+func ErrorResponseFor(accept string, err error, instance string) (string, int, interface{}) {
+	if AcceptsProblem(accept) {
+		p := NewProblemFromError(err, instance)
+		return ContentTypeProblemJSON, p.StatusCode, p
+	}
+	restErr := ParseErrors(err)
+	return ContentTypeJSON, restErr.Status(), restErr
+}
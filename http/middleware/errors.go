@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	httperrors "github.com/Abhi-singh-karuna/my_Liberary/http/errors"
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderRequestID carries the trace id added to problem responses
+const HeaderRequestID = "X-Request-ID"
+
+// ErrorHandler turns the last error a handler added with c.Error into the
+// response, using httperrors.ParseErrors for the status and JSON body, or an
+// RFC 7807 problem when the client accepts application/problem+json. Server
+// errors are logged with their chain and stack, the client only gets the
+// safe parts. Nothing is written when the handler already responded.
+func ErrorHandler(log logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		last := c.Errors.Last()
+		if last == nil {
+			return
+		}
+
+		contentType, status, body := httperrors.ErrorResponseFor(c.GetHeader("Accept"), last.Err, c.Request.URL.Path)
+		if status >= http.StatusInternalServerError {
+			errs.LogError(log, last.Err)
+		} else {
+			log.Debugf("%s %s failed with %d: %v", c.Request.Method, c.FullPath(), status, last.Err)
+		}
+
+		if c.Writer.Written() {
+			return
+		}
+		if problem, ok := body.(*httperrors.Problem); ok {
+			traceID := c.GetHeader(HeaderRequestID)
+			if traceID == "" {
+				traceID = c.Writer.Header().Get(HeaderRequestID)
+			}
+			problem.WithTraceID(traceID)
+		}
+		c.Header("Content-Type", contentType)
+		c.AbortWithStatusJSON(status, body)
+	}
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/cachehandler"
+	httperrors "github.com/Abhi-singh-karuna/my_Liberary/http/errors"
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotentReplayed  = "Idempotent-Replayed"
+	defaultIdempotencyTTL     = 24 * time.Hour
+	defaultIdempotencyLockTTL = time.Minute
+	idempotencyCompressAbove  = 1024
+	idempotencyStoreTimeout   = 5 * time.Second
+)
+
+// IdempotencyConfig configures Idempotency. Responses are kept for TTL, Methods
+// defaults to POST and PATCH and Scope, e.g. ByUser, keeps the keys of
+// different clients apart.
+type IdempotencyConfig struct {
+	Prefix  string
+	TTL     time.Duration
+	LockTTL time.Duration
+	Methods []string
+	Scope   KeyFunc
+}
+
+// idempotencyRecord is the stored first response of a key with the
+// fingerprint of its request
+type idempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// bufferedWriter keeps a copy of the response written through it
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the first response of a request carrying an
+// Idempotency-Key header to its retries. A retry arriving while the first
+// request runs gets a 409, reusing a key on another route or with another body
+// gets a 422. Server errors are not stored, so they can be retried.
+func Idempotency(log logger.Logger, cache cachehandler.CacheHandler, cfg IdempotencyConfig) gin.HandlerFunc {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultIdempotencyTTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = defaultIdempotencyLockTTL
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	methods := make(map[string]bool, len(cfg.Methods))
+	for _, m := range cfg.Methods {
+		methods[m] = true
+	}
+	records := cachehandler.NewObjectCache[idempotencyRecord](cache, cachehandler.JSONCodec, idempotencyCompressAbove)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if key == "" || !methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+
+		if cfg.Scope != nil {
+			key = cfg.Scope(c) + ":" + key
+		}
+		key = cfg.Prefix + key
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abort(c, httperrors.NewBadRequestError(err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := fingerprint(c, body)
+
+		ctx := c.Request.Context()
+		if replay(c, records, key, hash, log) {
+			return
+		}
+
+		lock, err := cache.TryLock(ctx, key+":lock", cfg.LockTTL)
+		if errors.Is(err, cachehandler.ErrLockNotObtained) {
+			abort(c, httperrors.NewRestError(http.StatusConflict, httperrors.RequestInProgress.Error(), nil))
+			return
+		}
+		if err != nil {
+			log.Errorf("Idempotency lock for %s failed: %v", key, err)
+			c.Next()
+			return
+		}
+		// a client that went away is the one retrying, so the response is
+		// stored and the lock released even when the request was canceled
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+			defer cancel()
+			lock.Release(ctx)
+		}()
+
+		// the first request may have finished between the lookup and the lock
+		if replay(c, records, key, hash, log) {
+			return
+		}
+
+		w := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		status := w.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		record := idempotencyRecord{
+			Fingerprint: hash,
+			Status:      status,
+			Header:      w.Header().Clone(),
+			Body:        w.body.Bytes(),
+		}
+		storeCtx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+		if err := records.Set(storeCtx, key, record, cfg.TTL); err != nil {
+			log.Errorf("Storing idempotent response for %s failed: %v", key, err)
+		}
+	}
+}
+
+// replay writes the stored response of key, if any, and reports whether the
+// request has been answered
+func replay(c *gin.Context, records *cachehandler.ObjectCache[idempotencyRecord], key, hash string, log logger.Logger) bool {
+	record, err := records.Get(c.Request.Context(), key)
+	if err != nil {
+		if !cachehandler.IsMiss(err) {
+			log.Errorf("Reading idempotent response for %s failed: %v", key, err)
+		}
+		return false
+	}
+
+	if record.Fingerprint != hash {
+		abort(c, httperrors.NewRestError(http.StatusUnprocessableEntity, httperrors.IdempotencyKeyReused.Error(), nil))
+		return true
+	}
+
+	for name, values := range record.Header {
+		for _, v := range values {
+			c.Writer.Header().Add(name, v)
+		}
+	}
+	c.Header(HeaderIdempotentReplayed, "true")
+	c.Status(record.Status)
+	c.Writer.Write(record.Body)
+	c.Abort()
+	return true
+}
+
+// fingerprint identifies a request by its method, route and body
+func fingerprint(c *gin.Context, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+" "+c.FullPath()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func abort(c *gin.Context, restErr httperrors.RestErr) {
+	c.AbortWithStatusJSON(restErr.Status(), restErr)
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/cachehandler"
+	libhttp "github.com/Abhi-singh-karuna/my_Liberary/http"
+	httperrors "github.com/Abhi-singh-karuna/my_Liberary/http/errors"
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// KeyFunc returns the rate limit key of a request, empty to skip limiting
+type KeyFunc func(*gin.Context) string
+
+// ByIP limits per client IP address
+func ByIP(c *gin.Context) string {
+	return "ip:" + libhttp.GetIPAddress(c)
+}
+
+// ByUser limits per user id stored in the gin context under contextKey by
+// the authentication middleware, and per IP for anonymous requests
+func ByUser(contextKey string) KeyFunc {
+	return func(c *gin.Context) string {
+		if id := c.GetString(contextKey); id != "" {
+			return "user:" + id
+		}
+		return ByIP(c)
+	}
+}
+
+// RateLimit rejects requests over limit with a 429 RestError. Redis failures
+// are logged and let the request through.
+func RateLimit(log logger.Logger, cache cachehandler.CacheHandler, prefix string, limit cachehandler.Limit, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		res, err := cache.Allow(c.Request.Context(), prefix+k, limit)
+		if err != nil {
+			log.Errorf("Rate limit check failed for %s: %v", k, err)
+			c.Next()
+			return
+		}
+
+		c.Header(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+		c.Header(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+		c.Header(HeaderRateLimitReset, seconds(res.ResetAfter))
+
+		if !res.Allowed {
+			c.Header(HeaderRetryAfter, seconds(res.RetryAfter))
+			restErr := httperrors.NewTooManyRequestsError(nil)
+			c.AbortWithStatusJSON(restErr.Status(), restErr)
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds rounds d up to whole seconds as the headers expect
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
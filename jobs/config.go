@@ -0,0 +1,46 @@
+package jobs
+
+import "time"
+
+type Config struct {
+	Table           string
+	Queue           string
+	Concurrency     int
+	PollInterval    time.Duration
+	Lease           time.Duration
+	RetryBackoff    time.Duration
+	MaxBackoff      time.Duration
+	ShutdownTimeout time.Duration
+}
+
+func (c *Config) GetTable() string {
+	return c.Table
+}
+
+func (c *Config) GetQueue() string {
+	return c.Queue
+}
+
+func (c *Config) GetConcurrency() int {
+	return c.Concurrency
+}
+
+func (c *Config) GetPollInterval() time.Duration {
+	return c.PollInterval
+}
+
+func (c *Config) GetLease() time.Duration {
+	return c.Lease
+}
+
+func (c *Config) GetRetryBackoff() time.Duration {
+	return c.RetryBackoff
+}
+
+func (c *Config) GetMaxBackoff() time.Duration {
+	return c.MaxBackoff
+}
+
+func (c *Config) GetShutdownTimeout() time.Duration {
+	return c.ShutdownTimeout
+}
@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	"github.com/Abhi-singh-karuna/my_Liberary/gateway"
+	"github.com/Abhi-singh-karuna/my_Liberary/repository"
+)
+
+const (
+	defaultTable       = "jobs"
+	defaultQueue       = "default"
+	defaultMaxAttempts = 5
+
+	statusPending = "pending"
+	statusRunning = "running"
+	statusDone    = "done"
+	statusFailed  = "failed"
+)
+
+// MySQLSchema and PostgresSchema create the jobs table, formatted with its name
+const (
+	MySQLSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	kind VARCHAR(255) NOT NULL,
+	payload LONGBLOB NOT NULL,
+	priority INT NOT NULL DEFAULT 0,
+	status VARCHAR(16) NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL,
+	last_error TEXT,
+	run_at DATETIME(6) NOT NULL,
+	locked_until DATETIME(6) NULL,
+	created_at DATETIME(6) NOT NULL,
+	finished_at DATETIME(6) NULL,
+	INDEX %[1]s_claim (queue, status, priority, run_at)
+)`
+	PostgresSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id BIGSERIAL PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	kind VARCHAR(255) NOT NULL,
+	payload BYTEA NOT NULL,
+	priority INT NOT NULL DEFAULT 0,
+	status VARCHAR(16) NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL,
+	last_error TEXT,
+	run_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP NULL,
+	created_at TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP NULL
+);
+CREATE INDEX IF NOT EXISTS %[1]s_claim ON %[1]s (queue, status, priority DESC, run_at)`
+)
+
+// Job is a claimed job handed to a HandlerFunc
+type Job struct {
+	ID          int64
+	Queue       string
+	Kind        string
+	Payload     []byte
+	Priority    int
+	Attempts    int
+	MaxAttempts int
+}
+
+// Request describes a job to enqueue. Jobs with a higher Priority run first,
+// a zero RunAt means now and a zero MaxAttempts means 5.
+type Request struct {
+	Queue       string
+	Kind        string `validate:"required"`
+	Payload     []byte
+	Priority    int
+	RunAt       time.Time
+	MaxAttempts int
+}
+
+type Queue interface {
+	Enqueue(gateway.Tx, Request) (int64, error)
+}
+
+type queue struct {
+	dialect repository.Dialect
+	table   string
+}
+
+func NewQueue(dialect repository.Dialect, cfg Config) Queue {
+	return &queue{
+		dialect: dialect,
+		table:   orDefault(cfg.GetTable(), defaultTable),
+	}
+}
+
+// Schema returns the statements creating the jobs table for the dialect,
+// ready for SqlHandler.MultiExec
+func Schema(dialect repository.Dialect, table string) string {
+	if dialect == repository.Postgres {
+		return fmt.Sprintf(PostgresSchema, orDefault(table, defaultTable))
+	}
+	return fmt.Sprintf(MySQLSchema, orDefault(table, defaultTable))
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// Enqueue stores the job and returns its id. db is either the SqlHandler or a
+// Tx, in which case the job only becomes visible when the transaction commits.
+func (q *queue) Enqueue(db gateway.Tx, req Request) (int64, error) {
+	if req.Kind == "" {
+		return 0, errs.Invalidated.New("job without kind")
+	}
+
+	now := time.Now().UTC()
+	runAt := req.RunAt.UTC()
+	if req.RunAt.IsZero() {
+		runAt = now
+	}
+	maxAttempts := req.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	payload := req.Payload
+	if payload == nil {
+		payload = []byte{}
+	}
+
+	query := strings.Join([]string{
+		"INSERT INTO ", q.table,
+		" (queue, kind, payload, priority, status, max_attempts, run_at, created_at)",
+		" VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+	}, "")
+	args := []interface{}{orDefault(req.Queue, defaultQueue), req.Kind, payload, req.Priority, statusPending, maxAttempts, runAt, now}
+
+	if q.dialect != repository.Postgres {
+		res, err := db.Exec(query, args...)
+		if err != nil {
+			return 0, err
+		}
+		return res.LastInsertId()
+	}
+
+	rows, err := db.Query(q.dialect.Rebind(query+" RETURNING id"), args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var id int64
+	if !rows.Next() {
+		return 0, errs.Failed.New("insert of job returned no id")
+	}
+	if err := rows.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
@@ -0,0 +1,392 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/gateway"
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+	"github.com/Abhi-singh-karuna/my_Liberary/repository"
+)
+
+const (
+	defaultConcurrency     = 4
+	defaultPollInterval    = time.Second
+	defaultLease           = 15 * time.Minute
+	defaultRetryBackoff    = 5 * time.Second
+	defaultMaxBackoff      = time.Hour
+	defaultShutdownTimeout = 30 * time.Second
+	maxErrorLength         = 1024
+)
+
+// HandlerFunc runs a job. Returning an error schedules a retry with backoff
+// until the job runs out of attempts.
+type HandlerFunc func(context.Context, Job) error
+
+type Worker interface {
+	Handle(string, HandlerFunc)
+	Run(context.Context) error
+}
+
+type worker struct {
+	log             logger.Logger
+	handler         gateway.SqlHandler
+	dialect         repository.Dialect
+	table           string
+	queue           string
+	concurrency     int
+	pollInterval    time.Duration
+	lease           time.Duration
+	retryBackoff    time.Duration
+	maxBackoff      time.Duration
+	shutdownTimeout time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+}
+
+func NewWorker(log logger.Logger, handler gateway.SqlHandler, dialect repository.Dialect, cfg Config) Worker {
+	w := &worker{
+		log:             log,
+		handler:         handler,
+		dialect:         dialect,
+		table:           orDefault(cfg.GetTable(), defaultTable),
+		queue:           orDefault(cfg.GetQueue(), defaultQueue),
+		concurrency:     cfg.GetConcurrency(),
+		pollInterval:    cfg.GetPollInterval(),
+		lease:           cfg.GetLease(),
+		retryBackoff:    cfg.GetRetryBackoff(),
+		maxBackoff:      cfg.GetMaxBackoff(),
+		shutdownTimeout: cfg.GetShutdownTimeout(),
+		handlers:        make(map[string]HandlerFunc),
+	}
+	if w.concurrency <= 0 {
+		w.concurrency = defaultConcurrency
+	}
+	if w.pollInterval <= 0 {
+		w.pollInterval = defaultPollInterval
+	}
+	if w.lease <= 0 {
+		w.lease = defaultLease
+	}
+	if w.retryBackoff <= 0 {
+		w.retryBackoff = defaultRetryBackoff
+	}
+	if w.maxBackoff <= 0 {
+		w.maxBackoff = defaultMaxBackoff
+	}
+	if w.shutdownTimeout <= 0 {
+		w.shutdownTimeout = defaultShutdownTimeout
+	}
+	return w
+}
+
+// Handle registers the function running jobs of the given kind
+func (w *worker) Handle(kind string, h HandlerFunc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[kind] = h
+}
+
+// Run claims and runs jobs with at most Concurrency in flight until ctx is
+// done. It then stops claiming and waits up to ShutdownTimeout for running
+// jobs, whose context is canceled once the timeout is over.
+func (w *worker) Run(ctx context.Context) error {
+	w.log.Infof("Job worker started on queue %s", w.queue)
+	defer w.log.Infof("Job worker stopped on queue %s", w.queue)
+
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, w.concurrency)
+	for ctx.Err() == nil {
+		free := w.concurrency - len(slots)
+		if free == 0 {
+			select {
+			case slots <- struct{}{}:
+				<-slots
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		claimed, err := w.claim(free)
+		if err != nil {
+			w.log.Error(err)
+		}
+		for _, job := range claimed {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(job Job) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				w.run(jobCtx, job)
+			}(job)
+		}
+
+		if len(claimed) < free {
+			timer := time.NewTimer(w.pollInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(w.shutdownTimeout):
+		w.log.Warn("Job worker shutdown timed out, canceling running jobs")
+		cancelJobs()
+		<-done
+	}
+	return nil
+}
+
+// claim locks up to limit due jobs with FOR UPDATE SKIP LOCKED and leases them
+// to this worker. Jobs whose lease expired, e.g. after a crash, are claimed
+// again while they have attempts left and failed otherwise, so a job killing
+// its worker is not retried forever.
+func (w *worker) claim(limit int) ([]Job, error) {
+	v, err := w.handler.TransactionWithTx(func(tx gateway.Tx) (interface{}, error) {
+		now := time.Now().UTC()
+		if err := w.failExpired(tx, now); err != nil {
+			return nil, err
+		}
+
+		query := strings.Join([]string{
+			"SELECT id, queue, kind, payload, priority, attempts, max_attempts FROM ", w.table,
+			" WHERE queue = ? AND ((status = ? AND run_at <= ?) OR (status = ? AND locked_until <= ? AND attempts < max_attempts))",
+			" ORDER BY priority DESC, run_at, id LIMIT ? FOR UPDATE SKIP LOCKED",
+		}, "")
+		rows, err := tx.Query(w.dialect.Rebind(query), w.queue, statusPending, now, statusRunning, now, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		var claimed []Job
+		for rows.Next() {
+			var job Job
+			if err := rows.Scan(&job.ID, &job.Queue, &job.Kind, &job.Payload, &job.Priority, &job.Attempts, &job.MaxAttempts); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			job.Attempts++
+			claimed = append(claimed, job)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if len(claimed) == 0 {
+			return claimed, nil
+		}
+
+		ids := make([]interface{}, 0, len(claimed)+3)
+		ids = append(ids, statusRunning, now.Add(w.lease))
+		for _, job := range claimed {
+			ids = append(ids, job.ID)
+		}
+		update := strings.Join([]string{
+			"UPDATE ", w.table, " SET status = ?, attempts = attempts + 1, locked_until = ?",
+			" WHERE id IN (", strings.TrimSuffix(strings.Repeat("?, ", len(claimed)), ", "), ")",
+		}, "")
+		if _, err := tx.Exec(w.dialect.Rebind(update), ids...); err != nil {
+			return nil, err
+		}
+		return claimed, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]Job), nil
+}
+
+// failExpired fails the jobs whose lease expired on their last attempt
+func (w *worker) failExpired(tx gateway.Tx, now time.Time) error {
+	query := strings.Join([]string{
+		"SELECT id, kind, attempts FROM ", w.table,
+		" WHERE queue = ? AND status = ? AND locked_until <= ? AND attempts >= max_attempts",
+		" FOR UPDATE SKIP LOCKED",
+	}, "")
+	rows, err := tx.Query(w.dialect.Rebind(query), w.queue, statusRunning, now)
+	if err != nil {
+		return err
+	}
+
+	var expired []Job
+	for rows.Next() {
+		var job Job
+		if err := rows.Scan(&job.ID, &job.Kind, &job.Attempts); err != nil {
+			rows.Close()
+			return err
+		}
+		expired = append(expired, job)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(expired)+3)
+	args = append(args, statusFailed, now, "lease expired on the last attempt")
+	for _, job := range expired {
+		w.log.Errorf("Job %d of kind %s failed after %d attempts: lease expired", job.ID, job.Kind, job.Attempts)
+		args = append(args, job.ID)
+	}
+	update := strings.Join([]string{
+		"UPDATE ", w.table, " SET status = ?, finished_at = ?, locked_until = NULL, last_error = ?",
+		" WHERE id IN (", strings.TrimSuffix(strings.Repeat("?, ", len(expired)), ", "), ")",
+	}, "")
+	_, err = tx.Exec(w.dialect.Rebind(update), args...)
+	return err
+}
+
+func (w *worker) run(ctx context.Context, job Job) {
+	w.mu.RLock()
+	h, ok := w.handlers[job.Kind]
+	w.mu.RUnlock()
+
+	var err error
+	final := false
+	if !ok {
+		err = fmt.Errorf("no handler for job kind %s", job.Kind)
+		final = true
+	} else {
+		ctx, cancel := context.WithCancel(ctx)
+		stop := w.heartbeat(ctx, cancel, job)
+		err = w.call(ctx, h, job)
+		stop()
+		cancel()
+	}
+
+	if err == nil {
+		err = w.finish(job, statusDone, "")
+	} else {
+		err = w.fail(job, err, final)
+	}
+	if err != nil {
+		w.log.Error(err)
+	}
+}
+
+// heartbeat renews the lease of a running job every third of the lease, so a
+// job running longer than the lease is not claimed again. When the lease turns
+// out to be lost the job context is canceled. The returned func stops it.
+func (w *worker) heartbeat(ctx context.Context, cancel context.CancelFunc, job Job) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(w.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			owned, err := w.update(job, "locked_until = ?", time.Now().UTC().Add(w.lease))
+			if err != nil {
+				// keep trying, the lease is still valid for two thirds
+				w.log.Warnf("Renewing the lease of job %d failed: %v", job.ID, err)
+				continue
+			}
+			if !owned {
+				w.log.Warnf("Job %d of kind %s lost its lease, canceling it", job.ID, job.Kind)
+				cancel()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// update sets the given columns on a job as long as this worker still holds
+// its lease, i.e. the job is running the attempt that was claimed, and
+// reports whether it did
+func (w *worker) update(job Job, set string, args ...interface{}) (bool, error) {
+	query := "UPDATE " + w.table + " SET " + set + " WHERE id = ? AND status = ? AND attempts = ?"
+	args = append(args, job.ID, statusRunning, job.Attempts)
+	res, err := w.handler.Exec(w.dialect.Rebind(query), args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// call runs the handler, turning a panic into an error
+func (w *worker) call(ctx context.Context, h HandlerFunc, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+func (w *worker) fail(job Job, cause error, final bool) error {
+	reason := cause.Error()
+	if len(reason) > maxErrorLength {
+		reason = reason[:maxErrorLength]
+	}
+
+	if final || job.Attempts >= job.MaxAttempts {
+		w.log.Errorf("Job %d of kind %s failed after %d attempts: %v", job.ID, job.Kind, job.Attempts, cause)
+		return w.finish(job, statusFailed, reason)
+	}
+
+	w.log.Warnf("Job %d of kind %s failed, attempt %d: %v", job.ID, job.Kind, job.Attempts, cause)
+	owned, err := w.update(job, "status = ?, run_at = ?, locked_until = NULL, last_error = ?",
+		statusPending, time.Now().UTC().Add(w.backoff(job.Attempts)), reason)
+	if err == nil && !owned {
+		w.log.Warnf("Job %d lost its lease, its retry is left to the new owner", job.ID)
+	}
+	return err
+}
+
+func (w *worker) finish(job Job, status, reason string) error {
+	var lastError interface{}
+	if reason != "" {
+		lastError = reason
+	}
+	owned, err := w.update(job, "status = ?, finished_at = ?, locked_until = NULL, last_error = ?",
+		status, time.Now().UTC(), lastError)
+	if err == nil && !owned {
+		w.log.Warnf("Job %d lost its lease, its %s result is discarded", job.ID, status)
+	}
+	return err
+}
+
+func (w *worker) backoff(attempts int) time.Duration {
+	d := w.retryBackoff
+	for i := 1; i < attempts && d < w.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.maxBackoff {
+		return w.maxBackoff
+	}
+	return d
+}
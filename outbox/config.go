@@ -0,0 +1,41 @@
+package outbox
+
+import "time"
+
+type Config struct {
+	Table         string
+	NotifyChannel string
+	BatchSize     int
+	PollInterval  time.Duration
+	MaxAttempts   int
+	RetryBackoff  time.Duration
+	MaxBackoff    time.Duration
+}
+
+func (c *Config) GetTable() string {
+	return c.Table
+}
+
+func (c *Config) GetNotifyChannel() string {
+	return c.NotifyChannel
+}
+
+func (c *Config) GetBatchSize() int {
+	return c.BatchSize
+}
+
+func (c *Config) GetPollInterval() time.Duration {
+	return c.PollInterval
+}
+
+func (c *Config) GetMaxAttempts() int {
+	return c.MaxAttempts
+}
+
+func (c *Config) GetRetryBackoff() time.Duration {
+	return c.RetryBackoff
+}
+
+func (c *Config) GetMaxBackoff() time.Duration {
+	return c.MaxBackoff
+}
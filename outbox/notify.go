@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+	"github.com/Abhi-singh-karuna/my_Liberary/psqlhandler"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Notifier wakes the relay up before its poll interval elapses
+type Notifier interface {
+	Wait(context.Context) error
+	Close() error
+}
+
+type postgresNotifier struct {
+	log     logger.Logger
+	connect string
+	channel string
+	conn    *pgx.Conn
+}
+
+// NewPostgresNotifier LISTENs on channel over a dedicated connection, matching
+// the pg_notify sent by Outbox.Add
+func NewPostgresNotifier(log logger.Logger, config psqlhandler.Config, channel string) Notifier {
+	return &postgresNotifier{
+		log: log,
+		connect: fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+			config.GetUser(), config.GetPassword(), config.GetHost(), config.GetPort(), config.GetDatabase()),
+		channel: channel,
+	}
+}
+
+func (n *postgresNotifier) Wait(ctx context.Context) error {
+	if n.conn == nil || n.conn.IsClosed() {
+		conn, err := pgx.Connect(ctx, n.connect)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{n.channel}.Sanitize()); err != nil {
+			conn.Close(context.Background())
+			return err
+		}
+		n.log.Debugf("Listening for outbox notifications on %s", n.channel)
+		n.conn = conn
+	}
+
+	_, err := n.conn.WaitForNotification(ctx)
+	return err
+}
+
+func (n *postgresNotifier) Close() error {
+	if n.conn == nil {
+		return nil
+	}
+	return n.conn.Close(context.Background())
+}
@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	"github.com/Abhi-singh-karuna/my_Liberary/gateway"
+	"github.com/Abhi-singh-karuna/my_Liberary/repository"
+)
+
+const defaultTable = "outbox"
+
+// MySQLSchema and PostgresSchema create the outbox table, formatted with its name
+const (
+	MySQLSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	topic VARCHAR(255) NOT NULL,
+	event_key VARCHAR(255) NOT NULL DEFAULT '',
+	payload LONGBLOB NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT,
+	created_at DATETIME(6) NOT NULL,
+	next_attempt_at DATETIME(6) NOT NULL,
+	delivered_at DATETIME(6) NULL,
+	dead_at DATETIME(6) NULL,
+	INDEX %[1]s_pending (delivered_at, dead_at, next_attempt_at)
+)`
+	PostgresSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id BIGSERIAL PRIMARY KEY,
+	topic VARCHAR(255) NOT NULL,
+	event_key VARCHAR(255) NOT NULL DEFAULT '',
+	payload BYTEA NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT,
+	created_at TIMESTAMP NOT NULL,
+	next_attempt_at TIMESTAMP NOT NULL,
+	delivered_at TIMESTAMP NULL,
+	dead_at TIMESTAMP NULL
+);
+CREATE INDEX IF NOT EXISTS %[1]s_pending ON %[1]s (next_attempt_at) WHERE delivered_at IS NULL AND dead_at IS NULL`
+)
+
+// Event is a message to publish once the surrounding transaction commits
+type Event struct {
+	Topic   string
+	Key     string
+	Payload []byte
+}
+
+type Outbox interface {
+	Add(gateway.Tx, ...Event) error
+}
+
+type outbox struct {
+	dialect repository.Dialect
+	table   string
+	channel string
+}
+
+func NewOutbox(dialect repository.Dialect, cfg Config) Outbox {
+	return &outbox{
+		dialect: dialect,
+		table:   tableName(cfg.GetTable()),
+		channel: cfg.GetNotifyChannel(),
+	}
+}
+
+// Schema returns the statements creating the outbox table for the dialect,
+// ready for SqlHandler.MultiExec
+func Schema(dialect repository.Dialect, table string) string {
+	if dialect == repository.Postgres {
+		return fmt.Sprintf(PostgresSchema, tableName(table))
+	}
+	return fmt.Sprintf(MySQLSchema, tableName(table))
+}
+
+func tableName(table string) string {
+	if table == "" {
+		return defaultTable
+	}
+	return table
+}
+
+// Add stores the events through tx, so they are only relayed if the business
+// write in the same transaction commits. On Postgres with a notify channel
+// configured, waiting relays are woken up on commit.
+func (o *outbox) Add(tx gateway.Tx, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	values := make([]string, len(events))
+	args := make([]interface{}, 0, len(events)*5)
+	for i, event := range events {
+		if event.Topic == "" {
+			return errs.Invalidated.New("outbox event without topic")
+		}
+		values[i] = "(?, ?, ?, ?, ?)"
+		args = append(args, event.Topic, event.Key, event.Payload, now, now)
+	}
+
+	query := strings.Join([]string{
+		"INSERT INTO ", o.table, " (topic, event_key, payload, created_at, next_attempt_at) VALUES ",
+		strings.Join(values, ", "),
+	}, "")
+	if _, err := tx.Exec(o.dialect.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	if o.dialect == repository.Postgres && o.channel != "" {
+		if _, err := tx.Exec("SELECT pg_notify($1, '')", o.channel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
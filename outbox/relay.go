@@ -0,0 +1,200 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/gateway"
+	"github.com/Abhi-singh-karuna/my_Liberary/logger"
+	"github.com/Abhi-singh-karuna/my_Liberary/repository"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	defaultMaxAttempts  = 10
+	defaultRetryBackoff = time.Second
+	defaultMaxBackoff   = 10 * time.Minute
+	maxErrorLength      = 1024
+)
+
+type Relay interface {
+	Run(context.Context) error
+}
+
+type relay struct {
+	log          logger.Logger
+	handler      gateway.SqlHandler
+	dialect      repository.Dialect
+	sink         Sink
+	notifier     Notifier
+	table        string
+	batchSize    int
+	pollInterval time.Duration
+	maxAttempts  int
+	retryBackoff time.Duration
+	maxBackoff   time.Duration
+}
+
+// NewRelay creates the worker moving outbox rows to sink. notifier is optional,
+// without it the relay only polls.
+func NewRelay(log logger.Logger, handler gateway.SqlHandler, dialect repository.Dialect, sink Sink, notifier Notifier, cfg Config) Relay {
+	r := &relay{
+		log:          log,
+		handler:      handler,
+		dialect:      dialect,
+		sink:         sink,
+		notifier:     notifier,
+		table:        tableName(cfg.GetTable()),
+		batchSize:    cfg.GetBatchSize(),
+		pollInterval: cfg.GetPollInterval(),
+		maxAttempts:  cfg.GetMaxAttempts(),
+		retryBackoff: cfg.GetRetryBackoff(),
+		maxBackoff:   cfg.GetMaxBackoff(),
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultBatchSize
+	}
+	if r.pollInterval <= 0 {
+		r.pollInterval = defaultPollInterval
+	}
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = defaultMaxAttempts
+	}
+	if r.retryBackoff <= 0 {
+		r.retryBackoff = defaultRetryBackoff
+	}
+	if r.maxBackoff <= 0 {
+		r.maxBackoff = defaultMaxBackoff
+	}
+	return r
+}
+
+// Run relays pending messages until ctx is done. Rows are claimed with
+// FOR UPDATE SKIP LOCKED, so several relays can run against the same table.
+func (r *relay) Run(ctx context.Context) error {
+	r.log.Info("Outbox relay started")
+	defer r.log.Info("Outbox relay stopped")
+
+	for {
+		n, err := r.relayBatch(ctx)
+		if err != nil {
+			r.log.Error(err)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil && n == r.batchSize {
+			continue
+		}
+		r.wait(ctx)
+	}
+}
+
+func (r *relay) wait(ctx context.Context) {
+	waitCtx, cancel := context.WithTimeout(ctx, r.pollInterval)
+	defer cancel()
+
+	if r.notifier != nil {
+		err := r.notifier.Wait(waitCtx)
+		if err == nil || errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return
+		}
+		r.log.Warnf("Outbox notifier failed, falling back to polling: %v", err)
+	}
+	<-waitCtx.Done()
+}
+
+// relayBatch publishes one batch of due messages and returns how many were claimed
+func (r *relay) relayBatch(ctx context.Context) (int, error) {
+	v, err := r.handler.TransactionWithTx(func(tx gateway.Tx) (interface{}, error) {
+		messages, err := r.claim(tx)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, m := range messages {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := r.sink.Publish(ctx, m); err != nil {
+				if err := r.fail(tx, m, err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			if err := r.delivered(tx, m); err != nil {
+				return 0, err
+			}
+		}
+		return len(messages), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+func (r *relay) claim(tx gateway.Tx) ([]Message, error) {
+	query := strings.Join([]string{
+		"SELECT id, topic, event_key, payload, attempts FROM ", r.table,
+		" WHERE delivered_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?",
+		" ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED",
+	}, "")
+	rows, err := tx.Query(r.dialect.Rebind(query), time.Now().UTC(), r.batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Key, &m.Payload, &m.Attempts); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func (r *relay) delivered(tx gateway.Tx, m Message) error {
+	query := "UPDATE " + r.table + " SET delivered_at = ?, attempts = attempts + 1 WHERE id = ?"
+	_, err := tx.Exec(r.dialect.Rebind(query), time.Now().UTC(), m.ID)
+	return err
+}
+
+// fail schedules a retry with exponential backoff, or dead-letters the message
+// once it ran out of attempts
+func (r *relay) fail(tx gateway.Tx, m Message, cause error) error {
+	attempts := m.Attempts + 1
+	reason := cause.Error()
+	if len(reason) > maxErrorLength {
+		reason = reason[:maxErrorLength]
+	}
+
+	if attempts >= r.maxAttempts {
+		r.log.Errorf("Outbox message %d on %s dead-lettered after %d attempts: %v", m.ID, m.Topic, attempts, cause)
+		query := "UPDATE " + r.table + " SET dead_at = ?, attempts = ?, last_error = ? WHERE id = ?"
+		_, err := tx.Exec(r.dialect.Rebind(query), time.Now().UTC(), attempts, reason, m.ID)
+		return err
+	}
+
+	r.log.Warnf("Outbox message %d on %s failed, attempt %d: %v", m.ID, m.Topic, attempts, cause)
+	query := "UPDATE " + r.table + " SET next_attempt_at = ?, attempts = ?, last_error = ? WHERE id = ?"
+	_, err := tx.Exec(r.dialect.Rebind(query), time.Now().UTC().Add(r.backoff(attempts)), attempts, reason, m.ID)
+	return err
+}
+
+func (r *relay) backoff(attempts int) time.Duration {
+	d := r.retryBackoff
+	for i := 1; i < attempts && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.maxBackoff {
+		return r.maxBackoff
+	}
+	return d
+}
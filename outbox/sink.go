@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/cachehandler"
+)
+
+// Message is an outbox row handed to a Sink
+type Message struct {
+	ID       int64
+	Topic    string
+	Key      string
+	Payload  []byte
+	Attempts int
+}
+
+// Sink publishes relayed messages. Publishing must be idempotent on the
+// consumer side, a message may be delivered more than once.
+type Sink interface {
+	Publish(context.Context, Message) error
+}
+
+// SinkFunc adapts a function to Sink
+type SinkFunc func(context.Context, Message) error
+
+func (f SinkFunc) Publish(ctx context.Context, m Message) error {
+	return f(ctx, m)
+}
+
+type redisStreamSink struct {
+	messenger cachehandler.Messenger
+	prefix    string
+	maxLen    int64
+}
+
+// NewRedisStreamSink publishes every message to the stream prefix+topic
+// through the connection of a cache handler's Messenger, trimming it to about
+// maxLen entries when maxLen is positive
+func NewRedisStreamSink(messenger cachehandler.Messenger, prefix string, maxLen int64) Sink {
+	return &redisStreamSink{
+		messenger: messenger,
+		prefix:    prefix,
+		maxLen:    maxLen,
+	}
+}
+
+func (s *redisStreamSink) Publish(ctx context.Context, m Message) error {
+	values := map[string]interface{}{
+		"id":      strconv.FormatInt(m.ID, 10),
+		"key":     m.Key,
+		"payload": m.Payload,
+	}
+	_, err := s.messenger.XAdd(ctx, s.prefix+m.Topic, values, s.maxLen)
+	return err
+}
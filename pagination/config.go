@@ -0,0 +1,19 @@
+package pagination
+
+type Config struct {
+	DefaultLimit int
+	MaxLimit     int
+	Secret       string `validate:"required"`
+}
+
+func (c *Config) GetDefaultLimit() int {
+	return c.DefaultLimit
+}
+
+func (c *Config) GetMaxLimit() int {
+	return c.MaxLimit
+}
+
+func (c *Config) GetSecret() string {
+	return c.Secret
+}
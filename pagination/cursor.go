@@ -0,0 +1,84 @@
+package pagination
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var (
+	errMalformedCursor = errors.New("malformed cursor")
+	errInvalidCursor   = errors.New("invalid cursor signature")
+)
+
+// cursor is the payload behind the opaque cursor strings
+type cursor struct {
+	Keys     []interface{} `json:"k"`
+	Backward bool          `json:"b,omitempty"`
+}
+
+// encode serializes the cursor as base64url(payload).base64url(hmac)
+func (p *paginator) encode(c cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(p.sign(encoded)), nil
+}
+
+func (p *paginator) decode(s string) (*cursor, error) {
+	encoded, signature, found := strings.Cut(s, ".")
+	if !found {
+		return nil, errMalformedCursor
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+	if !hmac.Equal(sig, p.sign(encoded)) {
+		return nil, errInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+
+	var c cursor
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil || len(c.Keys) == 0 {
+		return nil, errMalformedCursor
+	}
+
+	for i, key := range c.Keys {
+		if n, ok := key.(json.Number); ok {
+			c.Keys[i] = number(n)
+		}
+	}
+	return &c, nil
+}
+
+func (p *paginator) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// number turns a decoded JSON number back into an int64 when it is integral
+func number(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
@@ -0,0 +1,206 @@
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	httperrors "github.com/Abhi-singh-karuna/my_Liberary/http/errors"
+	"github.com/Abhi-singh-karuna/my_Liberary/validator"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLimit    = 20
+	defaultMaxLimit = 100
+
+	QueryPage   = "page"
+	QueryLimit  = "limit"
+	QueryCursor = "cursor"
+)
+
+type Paginator interface {
+	Parse(*gin.Context) (*Params, error)
+}
+
+type paginator struct {
+	secret       []byte
+	defaultLimit int
+	maxLimit     int
+}
+
+// Params holds the pagination requested by the client
+type Params struct {
+	Page   int
+	Limit  int
+	cursor *cursor
+	p      *paginator
+}
+
+// NewPaginator fails when the config is invalid, cursors signed with an empty
+// secret could be forged by anyone
+func NewPaginator(cfg Config) (Paginator, error) {
+	if err := validator.ValidateStruct(context.Background(), &cfg); err != nil {
+		return nil, errs.Invalidated.Wrap(err, "invalid pagination config")
+	}
+
+	p := &paginator{
+		secret:       []byte(cfg.GetSecret()),
+		defaultLimit: cfg.GetDefaultLimit(),
+		maxLimit:     cfg.GetMaxLimit(),
+	}
+	if p.maxLimit <= 0 {
+		p.maxLimit = defaultMaxLimit
+	}
+	if p.defaultLimit <= 0 {
+		p.defaultLimit = defaultLimit
+	}
+	if p.defaultLimit > p.maxLimit {
+		p.defaultLimit = p.maxLimit
+	}
+	return p, nil
+}
+
+// Parse reads page, limit and cursor from the query string. Bad values are
+// reported as a http/errors RestErr with BadQueryParams.
+func (p *paginator) Parse(c *gin.Context) (*Params, error) {
+	params := &Params{Page: 1, Limit: p.defaultLimit, p: p}
+
+	if v, ok := c.GetQuery(QueryLimit); ok {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > p.maxLimit {
+			return nil, badQueryParams(fmt.Sprintf("%s must be between 1 and %d", QueryLimit, p.maxLimit))
+		}
+		params.Limit = limit
+	}
+
+	page, hasPage := c.GetQuery(QueryPage)
+	token, hasCursor := c.GetQuery(QueryCursor)
+	if hasPage && hasCursor {
+		return nil, badQueryParams(fmt.Sprintf("%s and %s can not be used together", QueryPage, QueryCursor))
+	}
+
+	if hasPage {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return nil, badQueryParams(fmt.Sprintf("%s must be a positive integer", QueryPage))
+		}
+		// the offset (page-1)*limit must not overflow
+		if n-1 > math.MaxInt/params.Limit {
+			return nil, badQueryParams(fmt.Sprintf("%s is too large", QueryPage))
+		}
+		params.Page = n
+	}
+
+	if hasCursor && token != "" {
+		cur, err := p.decode(token)
+		if err != nil {
+			return nil, badQueryParams(err.Error())
+		}
+		params.cursor = cur
+	}
+
+	return params, nil
+}
+
+func badQueryParams(causes interface{}) error {
+	return httperrors.NewRestError(http.StatusBadRequest, httperrors.BadQueryParams.Error(), causes)
+}
+
+// Backward reports whether the client asked for the page before its cursor
+func (params *Params) Backward() bool {
+	return params.cursor != nil && params.cursor.Backward
+}
+
+// Offset returns the LIMIT/OFFSET clause for page based listing. One row more
+// than the limit is requested so NewPage can tell whether a next page exists.
+func (params *Params) Offset() (string, []interface{}) {
+	return " LIMIT ? OFFSET ?", []interface{}{params.Limit + 1, (params.Page - 1) * params.Limit}
+}
+
+// Keyset returns the WHERE condition (empty on the first page), the ORDER BY
+// list and the LIMIT for keyset listing ordered ascending by columns, which must
+// identify a row uniquely. Placeholders are ?, as for repository.Find. Rows of a
+// backward page come back in descending order; NewPage puts them right. A
+// cursor with another number of keys than columns is a BadQueryParams error.
+func (params *Params) Keyset(columns ...string) (string, string, []interface{}, error) {
+	if params.cursor != nil && len(params.cursor.Keys) != len(columns) {
+		return "", "", nil, badQueryParams("cursor does not match the listing")
+	}
+
+	op, dir := ">", "ASC"
+	if params.Backward() {
+		op, dir = "<", "DESC"
+	}
+
+	order := make([]string, len(columns))
+	for i, column := range columns {
+		order[i] = column + " " + dir
+	}
+
+	var (
+		where string
+		args  []interface{}
+	)
+	if params.cursor != nil {
+		where = "(" + strings.Join(columns, ", ") + ") " + op +
+			" (" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+		args = append(args, params.cursor.Keys...)
+	}
+
+	return where, strings.Join(order, ", ") + " LIMIT ?", append(args, params.Limit+1), nil
+}
+
+// Page is the response envelope of a listing
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// NewPage builds the envelope from the rows fetched with the Offset or Keyset
+// clauses. key returns the keyset column values of an item and is nil for page
+// based listing.
+func NewPage[T any](params *Params, items []T, key func(T) []interface{}) (*Page[T], error) {
+	hasMore := len(items) > params.Limit
+	if hasMore {
+		items = items[:params.Limit]
+	}
+
+	page := &Page[T]{Items: items, Limit: params.Limit, HasMore: hasMore}
+	if key == nil {
+		page.Page = params.Page
+		return page, nil
+	}
+
+	backward := params.Backward()
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	var err error
+	if hasMore || backward {
+		if page.NextCursor, err = params.p.encode(cursor{Keys: key(items[len(items)-1])}); err != nil {
+			return nil, err
+		}
+	}
+	if backward && hasMore || !backward && params.cursor != nil {
+		if page.PrevCursor, err = params.p.encode(cursor{Keys: key(items[0]), Backward: true}); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
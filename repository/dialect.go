@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Dialect int
+
+const (
+	MySQL Dialect = iota
+	Postgres
+)
+
+// Quote quotes an identifier for the dialect
+func (d Dialect) Quote(identifier string) string {
+	if d == Postgres {
+		return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+	}
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+// Rebind converts ? placeholders to the dialect's bind variables,
+// leaving the ones inside quoted strings untouched
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var (
+		b     strings.Builder
+		n     int
+		quote byte
+	)
+	b.Grow(len(query) + 8)
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
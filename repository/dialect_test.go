@@ -0,0 +1,46 @@
+package repository
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{"mysql is unchanged", MySQL, "SELECT * FROM a WHERE id = ? AND v = ?", "SELECT * FROM a WHERE id = ? AND v = ?"},
+		{"postgres numbers placeholders", Postgres, "UPDATE a SET v = ? WHERE id = ? AND v = ?", "UPDATE a SET v = $1 WHERE id = $2 AND v = $3"},
+		{"no placeholders", Postgres, "SELECT 1", "SELECT 1"},
+		{"question marks in strings", Postgres, "SELECT '?' , \"a?\" FROM a WHERE id = ?", "SELECT '?' , \"a?\" FROM a WHERE id = $1"},
+		{"doubled quotes", Postgres, "SELECT 'it''s ?' WHERE id = ?", "SELECT 'it''s ?' WHERE id = $1"},
+		{"double digit placeholders", Postgres, "VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.Rebind(tt.query); got != tt.want {
+				t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		dialect    Dialect
+		identifier string
+		want       string
+	}{
+		{MySQL, "users", "`users`"},
+		{MySQL, "we`ird", "`we``ird`"},
+		{Postgres, "users", `"users"`},
+		{Postgres, `we"ird`, `"we""ird"`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.dialect.Quote(tt.identifier); got != tt.want {
+			t.Errorf("Quote(%q) = %s, want %s", tt.identifier, got, tt.want)
+		}
+	}
+}
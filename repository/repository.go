@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	"github.com/Abhi-singh-karuna/my_Liberary/gateway"
+)
+
+const (
+	defaultIDColumn      = "id"
+	defaultVersionColumn = "version"
+	defaultDeletedColumn = "deleted_at"
+)
+
+// Table describes the columns used for optimistic locking and soft deletes.
+// Empty column names fall back to id, version and deleted_at.
+type Table struct {
+	Name          string `validate:"required"`
+	IDColumn      string
+	VersionColumn string
+	DeletedColumn string
+}
+
+type Repository interface {
+	Insert(map[string]interface{}) (gateway.Result, error)
+	Update(interface{}, int64, map[string]interface{}) (int64, error)
+	SoftDelete(interface{}, int64) error
+	Restore(interface{}) error
+	Version(interface{}) (int64, error)
+	Find([]string, string, ...interface{}) (gateway.Row, error)
+	FindWithDeleted([]string, string, ...interface{}) (gateway.Row, error)
+}
+
+type repository struct {
+	handler gateway.SqlHandler
+	dialect Dialect
+	table   string
+	id      string
+	version string
+	deleted string
+}
+
+func NewRepository(handler gateway.SqlHandler, dialect Dialect, table Table) Repository {
+	return &repository{
+		handler: handler,
+		dialect: dialect,
+		table:   dialect.Quote(table.Name),
+		id:      dialect.Quote(orDefault(table.IDColumn, defaultIDColumn)),
+		version: dialect.Quote(orDefault(table.VersionColumn, defaultVersionColumn)),
+		deleted: dialect.Quote(orDefault(table.DeletedColumn, defaultDeletedColumn)),
+	}
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// Insert creates a row with its version set to 1
+func (r *repository) Insert(values map[string]interface{}) (gateway.Result, error) {
+	if len(values) == 0 {
+		return nil, errs.Invalidated.New("no values to insert")
+	}
+
+	columns, args := r.columns(values)
+	columns = append(columns, r.version)
+	args = append(args, 1)
+
+	query := strings.Join([]string{
+		"INSERT INTO ", r.table, " (", strings.Join(columns, ", "), ") VALUES (",
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "), ")",
+	}, "")
+	return r.handler.Exec(r.dialect.Rebind(query), args...)
+}
+
+// Update writes the values only if the row is still at the given version and
+// returns the new version. A stale version gives errs.Conflict, a missing or
+// soft deleted row gives errs.NotFound.
+func (r *repository) Update(id interface{}, version int64, values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, errs.Invalidated.New("no values to update")
+	}
+
+	columns, args := r.columns(values)
+	for i, column := range columns {
+		if column == r.id || column == r.version || column == r.deleted {
+			return 0, errs.Invalidated.Errorf("column %s can not be updated directly", column)
+		}
+		columns[i] = column + " = ?"
+	}
+	columns = append(columns, r.version+" = "+r.version+" + 1")
+	args = append(args, id, version)
+
+	query := strings.Join([]string{
+		"UPDATE ", r.table, " SET ", strings.Join(columns, ", "),
+		" WHERE ", r.id, " = ? AND ", r.version, " = ? AND ", r.deleted, " IS NULL",
+	}, "")
+	if err := r.execVersioned(query, id, version, args...); err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
+
+// SoftDelete marks the row as deleted if it is still at the given version
+func (r *repository) SoftDelete(id interface{}, version int64) error {
+	query := strings.Join([]string{
+		"UPDATE ", r.table, " SET ", r.deleted, " = CURRENT_TIMESTAMP, ", r.version, " = ", r.version, " + 1",
+		" WHERE ", r.id, " = ? AND ", r.version, " = ? AND ", r.deleted, " IS NULL",
+	}, "")
+	return r.execVersioned(query, id, version, id, version)
+}
+
+// Restore brings back a soft deleted row
+func (r *repository) Restore(id interface{}) error {
+	query := strings.Join([]string{
+		"UPDATE ", r.table, " SET ", r.deleted, " = NULL, ", r.version, " = ", r.version, " + 1",
+		" WHERE ", r.id, " = ? AND ", r.deleted, " IS NOT NULL",
+	}, "")
+	res, err := r.handler.Exec(r.dialect.Rebind(query), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.NotFound.Errorf("no deleted row in %s with id %v", r.table, id)
+	}
+	return nil
+}
+
+// Version returns the current version of a row that is not soft deleted
+func (r *repository) Version(id interface{}) (int64, error) {
+	query := strings.Join([]string{
+		"SELECT ", r.version, " FROM ", r.table,
+		" WHERE ", r.id, " = ? AND ", r.deleted, " IS NULL",
+	}, "")
+	row, err := r.handler.Query(r.dialect.Rebind(query), id)
+	if err != nil {
+		return 0, err
+	}
+	defer row.Close()
+
+	if !row.Next() {
+		return 0, errs.NotFound.Errorf("no row in %s with id %v", r.table, id)
+	}
+
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Find selects the rows matching where, skipping soft deleted ones.
+// where uses ? placeholders on every dialect and may be empty.
+func (r *repository) Find(columns []string, where string, args ...interface{}) (gateway.Row, error) {
+	condition := r.deleted + " IS NULL"
+	if where != "" {
+		condition = "(" + where + ") AND " + condition
+	}
+	return r.selectRows(columns, condition, args...)
+}
+
+// FindWithDeleted is Find including soft deleted rows
+func (r *repository) FindWithDeleted(columns []string, where string, args ...interface{}) (gateway.Row, error) {
+	return r.selectRows(columns, where, args...)
+}
+
+func (r *repository) selectRows(columns []string, where string, args ...interface{}) (gateway.Row, error) {
+	selected := "*"
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = r.dialect.Quote(column)
+		}
+		selected = strings.Join(quoted, ", ")
+	}
+
+	query := strings.Join([]string{"SELECT ", selected, " FROM ", r.table}, "")
+	if where != "" {
+		query += " WHERE " + where
+	}
+	return r.handler.Query(r.dialect.Rebind(query), args...)
+}
+
+// execVersioned runs a version guarded statement and tells a stale version
+// apart from a missing row when nothing was affected
+func (r *repository) execVersioned(query string, id interface{}, version int64, args ...interface{}) error {
+	res, err := r.handler.Exec(r.dialect.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	current, err := r.Version(id)
+	if err != nil {
+		return err
+	}
+	return errs.Conflict.Errorf("row in %s with id %v is at version %d, not %d", r.table, id, current, version)
+}
+
+// columns returns the quoted column names in a stable order with their values
+func (r *repository) columns(values map[string]interface{}) ([]string, []interface{}) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	columns := make([]string, len(names))
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		columns[i] = r.dialect.Quote(name)
+		args[i] = values[name]
+	}
+	return columns, args
+}
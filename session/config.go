@@ -0,0 +1,49 @@
+package session
+
+import (
+	"net/http"
+	"time"
+)
+
+type Config struct {
+	Secret     string `validate:"required,min=32"`
+	CookieName string
+	Domain     string
+	Path       string
+	Insecure   bool
+	SameSite   http.SameSite
+	TTL        time.Duration
+	KeyPrefix  string
+}
+
+func (c *Config) GetSecret() string {
+	return c.Secret
+}
+
+func (c *Config) GetCookieName() string {
+	return c.CookieName
+}
+
+func (c *Config) GetDomain() string {
+	return c.Domain
+}
+
+func (c *Config) GetPath() string {
+	return c.Path
+}
+
+func (c *Config) GetInsecure() bool {
+	return c.Insecure
+}
+
+func (c *Config) GetSameSite() http.SameSite {
+	return c.SameSite
+}
+
+func (c *Config) GetTTL() time.Duration {
+	return c.TTL
+}
+
+func (c *Config) GetKeyPrefix() string {
+	return c.KeyPrefix
+}
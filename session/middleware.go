@@ -0,0 +1,177 @@
+package session
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	httperrors "github.com/Abhi-singh-karuna/my_Liberary/http/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the gin context key of the loaded *Session
+const ContextKey = "session"
+
+var errNoCookie = errs.Unauthorized.New(httperrors.NoCookie.Error())
+
+type cookieConfig struct {
+	name     string
+	domain   string
+	path     string
+	secure   bool
+	sameSite http.SameSite
+}
+
+func newCookieConfig(cfg Config) cookieConfig {
+	c := cookieConfig{
+		name:     cfg.GetCookieName(),
+		domain:   cfg.GetDomain(),
+		path:     cfg.GetPath(),
+		secure:   !cfg.GetInsecure(),
+		sameSite: cfg.GetSameSite(),
+	}
+	if c.name == "" {
+		c.name = defaultCookieName
+	}
+	if c.path == "" {
+		c.path = defaultPath
+	}
+	if c.sameSite == 0 {
+		c.sameSite = http.SameSiteLaxMode
+	}
+	return c
+}
+
+// Middleware loads the session of the request cookie into the gin context.
+// With required set, requests without a valid session are aborted with a 401
+// RestError: NoCookie when there is no cookie, Unauthorized when the session
+// is invalid or expired.
+func (s *store) Middleware(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess, err := s.load(c)
+		if err != nil {
+			if !required && errs.GetType(err) == errs.Unauthorized {
+				c.Next()
+				return
+			}
+			restErr := restError(err)
+			c.AbortWithStatusJSON(restErr.Status(), restErr)
+			return
+		}
+
+		c.Set(ContextKey, sess)
+		c.Next()
+	}
+}
+
+func (s *store) load(c *gin.Context) (*Session, error) {
+	value, err := c.Cookie(s.cookie.name)
+	if err != nil || value == "" {
+		return nil, errNoCookie
+	}
+
+	id, ok := s.verify(value)
+	if !ok {
+		s.clearCookie(c)
+		return nil, errs.Unauthorized.New("invalid session cookie")
+	}
+
+	sess, touched, err := s.get(c.Request.Context(), id)
+	if err != nil {
+		if errs.GetType(err) == errs.Unauthorized {
+			s.clearCookie(c)
+		}
+		return nil, err
+	}
+	// the cookie expires TTL after it was set, so it slides with the session
+	if touched {
+		s.setCookie(c, id)
+	}
+	return sess, nil
+}
+
+func restError(err error) httperrors.RestErr {
+	switch {
+	case errs.GetType(err) != errs.Unauthorized:
+		return httperrors.NewInternalServerError(err)
+	case err == errNoCookie:
+		return httperrors.NewRestError(http.StatusUnauthorized, httperrors.NoCookie.Error(), err)
+	default:
+		return httperrors.NewUnauthorizedError(err)
+	}
+}
+
+// FromContext returns the session loaded by Middleware
+func FromContext(c *gin.Context) (*Session, bool) {
+	v, ok := c.Get(ContextKey)
+	if !ok {
+		return nil, false
+	}
+	sess, ok := v.(*Session)
+	return sess, ok
+}
+
+// Login creates a session for userID and sets its cookie on the response
+func (s *store) Login(c *gin.Context, userID string, values map[string]interface{}) (*Session, error) {
+	sess, err := s.Create(c.Request.Context(), userID, values)
+	if err != nil {
+		return nil, err
+	}
+	s.setCookie(c, sess.ID)
+	c.Set(ContextKey, sess)
+	return sess, nil
+}
+
+// RotateCookie gives the current session a new id and cookie, e.g. after the
+// user gained privileges
+func (s *store) RotateCookie(c *gin.Context) (*Session, error) {
+	sess, ok := FromContext(c)
+	if !ok {
+		return nil, errs.Unauthorized.New("no session to rotate")
+	}
+
+	rotated, err := s.Rotate(c.Request.Context(), sess)
+	if err != nil {
+		return nil, err
+	}
+	s.setCookie(c, rotated.ID)
+	c.Set(ContextKey, rotated)
+	return rotated, nil
+}
+
+// Logout destroys the current session and clears its cookie
+func (s *store) Logout(c *gin.Context) error {
+	s.clearCookie(c)
+	sess, ok := FromContext(c)
+	if !ok {
+		return nil
+	}
+	return s.Destroy(c.Request.Context(), sess.ID)
+}
+
+func (s *store) setCookie(c *gin.Context, id string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.cookie.name,
+		Value:    s.sign(id),
+		Path:     s.cookie.path,
+		Domain:   s.cookie.domain,
+		MaxAge:   int(s.ttl / time.Second),
+		Secure:   s.cookie.secure,
+		HttpOnly: true,
+		SameSite: s.cookie.sameSite,
+	})
+}
+
+func (s *store) clearCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.cookie.name,
+		Value:    "",
+		Path:     s.cookie.path,
+		Domain:   s.cookie.domain,
+		MaxAge:   -1,
+		Secure:   s.cookie.secure,
+		HttpOnly: true,
+		SameSite: s.cookie.sameSite,
+	})
+}
@@ -0,0 +1,259 @@
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Abhi-singh-karuna/my_Liberary/cachehandler"
+	"github.com/Abhi-singh-karuna/my_Liberary/errs"
+	"github.com/Abhi-singh-karuna/my_Liberary/validator"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCookieName = "session"
+	defaultPath       = "/"
+	defaultTTL        = 24 * time.Hour
+	defaultKeyPrefix  = "session:"
+	userTagPrefix     = "session-user:"
+	idBytes           = 32
+	slotLength        = 16
+)
+
+// Session is the server side data of a login
+type Session struct {
+	ID        string                 `json:"-"`
+	UserID    string                 `json:"user_id"`
+	Values    map[string]interface{} `json:"values,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	TouchedAt time.Time              `json:"touched_at"`
+}
+
+// Store keeps sessions in the cache, a session expires after TTL without use.
+// Login, RotateCookie, Logout and Middleware manage the session cookie of a
+// gin request.
+type Store interface {
+	Create(context.Context, string, map[string]interface{}) (*Session, error)
+	Get(context.Context, string) (*Session, error)
+	Save(context.Context, *Session) error
+	Rotate(context.Context, *Session) (*Session, error)
+	Destroy(context.Context, string) error
+	RevokeUser(context.Context, string) (int64, error)
+
+	Login(*gin.Context, string, map[string]interface{}) (*Session, error)
+	RotateCookie(*gin.Context) (*Session, error)
+	Logout(*gin.Context) error
+	Middleware(bool) gin.HandlerFunc
+}
+
+type store struct {
+	cache  cachehandler.CacheHandler
+	secret []byte
+	ttl    time.Duration
+	prefix string
+	cookie cookieConfig
+}
+
+// NewStore fails when the config is invalid, e.g. the secret signing the
+// cookies is shorter than 32 bytes
+func NewStore(cache cachehandler.CacheHandler, cfg Config) (Store, error) {
+	if err := validator.ValidateStruct(context.Background(), &cfg); err != nil {
+		return nil, errs.Invalidated.Wrap(err, "invalid session config")
+	}
+
+	s := &store{
+		cache:  cache,
+		secret: []byte(cfg.GetSecret()),
+		ttl:    cfg.GetTTL(),
+		prefix: cfg.GetKeyPrefix(),
+		cookie: newCookieConfig(cfg),
+	}
+	if s.ttl <= 0 {
+		s.ttl = defaultTTL
+	}
+	if s.prefix == "" {
+		s.prefix = defaultKeyPrefix
+	}
+	return s, nil
+}
+
+// Create starts a session for userID with a new random id
+func (s *store) Create(ctx context.Context, userID string, values map[string]interface{}) (*Session, error) {
+	id, err := newID(s.slot(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	sess := &Session{
+		ID:        id,
+		UserID:    userID,
+		Values:    values,
+		CreatedAt: now,
+		TouchedAt: now,
+	}
+	if err := s.Save(ctx, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Get loads a session and slides its expiry. A missing or expired session
+// gives errs.Unauthorized.
+func (s *store) Get(ctx context.Context, id string) (*Session, error) {
+	sess, _, err := s.get(ctx, id)
+	return sess, err
+}
+
+// get is Get also reporting whether the expiry was pushed back, which is
+// when Middleware re-issues the cookie
+func (s *store) get(ctx context.Context, id string) (*Session, bool, error) {
+	data, err := s.cache.GetContext(ctx, s.key(id)).Result()
+	if err != nil {
+		if cachehandler.IsMiss(err) {
+			return nil, false, errs.Unauthorized.New("session expired")
+		}
+		return nil, false, err
+	}
+
+	sess := &Session{}
+	if err := json.Unmarshal([]byte(data), sess); err != nil {
+		return nil, false, errs.Failed.Wrap(err, "decode session")
+	}
+	sess.ID = id
+
+	// the expiry is only pushed back every tenth of the ttl to spare writes
+	if time.Since(sess.TouchedAt) <= s.ttl/10 {
+		return sess, false, nil
+	}
+	sess.TouchedAt = time.Now().UTC()
+	if err := s.touch(ctx, sess); err != nil {
+		return nil, false, err
+	}
+	return sess, true, nil
+}
+
+// touch writes a loaded session for another TTL. Only an existing key is
+// written, so a session destroyed or revoked since it was read stays gone.
+func (s *store) touch(ctx context.Context, sess *Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return errs.Failed.Wrap(err, "encode session")
+	}
+
+	// the tag set is extended first, it must outlive the session for
+	// RevokeUser to find it
+	if _, err := s.cache.Expire(ctx, s.userTag(sess.UserID), s.ttl); err != nil {
+		return err
+	}
+	ok, err := s.cache.SetXX(ctx, s.key(sess.ID), string(data), s.ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errs.Unauthorized.New("session expired")
+	}
+	return nil
+}
+
+// Save writes the session for another TTL, tagged with its user so that
+// RevokeUser can find it. The session key and the tag set share a Redis
+// Cluster hash tag derived from the user, so the write works in every mode.
+func (s *store) Save(ctx context.Context, sess *Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return errs.Failed.Wrap(err, "encode session")
+	}
+
+	_, err = s.cache.SetWithTags(ctx, s.key(sess.ID), string(data), s.ttl, s.userTag(sess.UserID)).Result()
+	return err
+}
+
+// Rotate moves the session to a new id, to be called when its privileges
+// change so that an id captured before can not be used afterwards
+func (s *store) Rotate(ctx context.Context, sess *Session) (*Session, error) {
+	id, err := newID(s.slot(sess.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := *sess
+	rotated.ID = id
+	rotated.TouchedAt = time.Now().UTC()
+	if err := s.Save(ctx, &rotated); err != nil {
+		return nil, err
+	}
+	if err := s.Destroy(ctx, sess.ID); err != nil {
+		return nil, err
+	}
+	return &rotated, nil
+}
+
+func (s *store) Destroy(ctx context.Context, id string) error {
+	_, err := s.cache.DeleteContext(ctx, s.key(id)).Result()
+	return err
+}
+
+// RevokeUser ends every session of the user and returns how many there were
+func (s *store) RevokeUser(ctx context.Context, userID string) (int64, error) {
+	return s.cache.InvalidateTags(ctx, s.userTag(userID))
+}
+
+// newID returns the slot of the user followed by random bytes
+func newID(slot string) (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return slot + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// slot is the Redis Cluster hash tag of the sessions of a user, keyed by the
+// secret so the cookie does not reveal the user
+func (s *store) slot(userID string) string {
+	return hex.EncodeToString(s.mac("user:" + userID))[:slotLength]
+}
+
+// key is the cache key of a session, {slot}:random for ids made by newID
+func (s *store) key(id string) string {
+	if len(id) != slotLength+base64.RawURLEncoding.EncodedLen(idBytes) {
+		return s.prefix + id
+	}
+	return s.prefix + "{" + id[:slotLength] + "}:" + id[slotLength:]
+}
+
+func (s *store) userTag(userID string) string {
+	return userTagPrefix + "{" + s.slot(userID) + "}:" + userID
+}
+
+// sign returns the cookie value id.signature
+func (s *store) sign(id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(s.mac(id))
+}
+
+// verify returns the id of a signed cookie value
+func (s *store) verify(value string) (string, bool) {
+	id, signature, found := strings.Cut(value, ".")
+	if !found {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.mac(id)) {
+		return "", false
+	}
+	return id, true
+}
+
+func (s *store) mac(id string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id))
+	return mac.Sum(nil)
+}
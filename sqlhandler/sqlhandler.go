@@ -1,8 +1,10 @@
 package sqlhandler
 
 import (
+	"context"
 	"database/sql"
 	"strings"
+	"sync"
 
 	pvtconfig "github.com/Abhi-singh-karuna/my_Liberary/config"
 	"github.com/Abhi-singh-karuna/my_Liberary/errs"
@@ -17,10 +19,18 @@ const (
 	optionMultiStatements = "?parseTime=true&loc=UTC&multiStatements=true"
 )
 
+const (
+	multiMaxOpenConns = 5
+	multiMaxIdleConns = 2
+)
+
 type SqlHandler struct {
 	log     logger.Logger
 	DB      *sql.DB
 	connect string
+
+	multiMu sync.Mutex
+	multiDB *sql.DB
 }
 
 func newDB(connect, option string) (*sql.DB, error) {
@@ -95,14 +105,49 @@ func NewMapSqlHandler(log logger.Logger, sqlconfigs map[string]pvtconfig.SQL) ma
 	return mapSqlHandlers
 }
 
-func (handler *SqlHandler) MultiExec(multiStatements string) error {
+// multiStatementDB lazily opens the pool used for multi statement scripts and
+// keeps it for the lifetime of the handler
+func (handler *SqlHandler) multiStatementDB() (*sql.DB, error) {
+	handler.multiMu.Lock()
+	defer handler.multiMu.Unlock()
+
+	if handler.multiDB != nil {
+		return handler.multiDB, nil
+	}
+
 	handler.log.Debug("Connect to MySQL Database in multi statement mode")
 	db, err := newDB(handler.connect, optionMultiStatements)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxIdleConns(multiMaxIdleConns)
+	db.SetMaxOpenConns(multiMaxOpenConns)
+
+	handler.multiDB = db
+	return db, nil
+}
+
+// Close closes the database and the multi statement pool, if it was opened
+func (handler *SqlHandler) Close() error {
+	handler.multiMu.Lock()
+	defer handler.multiMu.Unlock()
+
+	err := handler.DB.Close()
+	if handler.multiDB != nil {
+		if multiErr := handler.multiDB.Close(); err == nil {
+			err = multiErr
+		}
+		handler.multiDB = nil
+	}
+	return err
+}
+
+func (handler *SqlHandler) MultiExec(multiStatements string) error {
+	db, err := handler.multiStatementDB()
 	if err != nil {
 		handler.log.Error(err)
 		return err
 	}
-	defer db.Close()
 
 	handler.log.Debug("Exec multi statements SQL")
 	_, err = db.Exec(multiStatements)
@@ -112,6 +157,46 @@ func (handler *SqlHandler) MultiExec(multiStatements string) error {
 	return err
 }
 
+// MultiExecWithResults runs the statements of the script one by one on a single
+// connection and returns their results. When a statement fails, the returned error
+// wraps a *gateway.StatementError carrying its index and line number.
+func (handler *SqlHandler) MultiExecWithResults(multiStatements string) ([]gateway.Result, error) {
+	db, err := handler.multiStatementDB()
+	if err != nil {
+		handler.log.Error(err)
+		return nil, errs.Failed.Wrap(err, err.Error())
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		handler.log.Error(err)
+		return nil, errs.Failed.Wrap(err, err.Error())
+	}
+	defer conn.Close()
+
+	statements := gateway.SplitStatements(multiStatements, gateway.MySQL)
+	results := make([]gateway.Result, 0, len(statements))
+
+	handler.log.Debugf("Exec %d statements SQL", len(statements))
+	for i, statement := range statements {
+		res, err := conn.ExecContext(ctx, statement.Query)
+		if err != nil {
+			stmtErr := &gateway.StatementError{
+				Index:     i,
+				Line:      statement.Line,
+				Statement: statement.Query,
+				Err:       err,
+			}
+			handler.log.Error(stmtErr)
+			return results, errs.Failed.Wrap(stmtErr, stmtErr.Error())
+		}
+		results = append(results, &SqlResult{Result: res})
+	}
+
+	return results, nil
+}
+
 func (handler *SqlHandler) Exec(statement string, args ...interface{}) (gateway.Result, error) {
 	handler.log.Debug("Prepare SQL statement for execution")
 	stmt, err := handler.DB.Prepare(statement)
@@ -181,6 +266,74 @@ func (handler *SqlHandler) Transaction(f func() (interface{}, error)) (interface
 	return v, nil
 }
 
+// TransactionWithTx is like Transaction but hands the transaction to f, so the
+// statements run through it are committed or rolled back together
+func (handler *SqlHandler) TransactionWithTx(f func(gateway.Tx) (interface{}, error)) (interface{}, error) {
+	handler.log.Debug("Begin SQL transaction")
+	tx, err := handler.DB.Begin()
+	if err != nil {
+		handler.log.Error(err)
+		return nil, errs.Failed.Wrap(err, err.Error())
+	}
+
+	v, err := f(&SqlTx{log: handler.log, Tx: tx})
+	if err != nil {
+		handler.log.Error(err)
+		handler.log.Warn("Rollback transaction")
+		eRollback := tx.Rollback()
+		if eRollback != nil {
+			err = errs.Failed.New(err.Error())
+			err = errs.Wrap(err, eRollback.Error())
+		}
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		handler.log.Error(err)
+		return nil, errs.Failed.Wrap(err, err.Error())
+	}
+
+	return v, nil
+}
+
+type SqlTx struct {
+	log logger.Logger
+	Tx  *sql.Tx
+}
+
+func (t *SqlTx) Exec(statement string, args ...interface{}) (gateway.Result, error) {
+	t.log.Debug("Prepare SQL statement for execution in transaction")
+	stmt, err := t.Tx.Prepare(statement)
+	if err != nil {
+		t.log.Error(err)
+		return nil, errs.Failed.Wrap(err, err.Error())
+	}
+	defer stmt.Close()
+
+	t.log.Debug("Execute prepared SQL statement in transaction")
+	res, err := stmt.Exec(args...)
+	if err != nil {
+		t.log.Error(err)
+		return nil, errs.Failed.Wrap(err, err.Error())
+	}
+
+	return &SqlResult{Result: res}, nil
+}
+
+func (t *SqlTx) Query(statement string, args ...interface{}) (gateway.Row, error) {
+	// a statement prepared on the transaction is closed in the driver right
+	// away, before the caller reads the rows, so the query is not prepared
+	t.log.Debug("Query SQL statement in transaction")
+	rows, err := t.Tx.Query(statement, args...)
+	if err != nil {
+		t.log.Error(err)
+		return nil, errs.Failed.Wrap(err, err.Error())
+	}
+
+	return &SqlRow{Rows: rows}, nil
+}
+
 type SqlResult struct {
 	Result sql.Result
 }